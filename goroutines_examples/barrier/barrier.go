@@ -0,0 +1,146 @@
+// Package barrier lets groups of goroutines wait for each other.
+//
+// WaterFactoryWithLeader in the h2o package is a barrier in disguise: atoms
+// wait until a molecule is complete, the oxygen leads the bond, and the
+// oxygenMutex keeps the next molecule out until the last one is done.
+// CyclicBarrier is that pattern for any number of parties, and Phaser lets
+// parties join and leave between rounds.
+//
+// A party that gives up while others wait for it breaks the barrier, so
+// that the others are not left waiting forever.
+package barrier
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBroken is returned to parties of a broken barrier
+var ErrBroken = errors.New("barrier: broken")
+
+// generation is one round of a CyclicBarrier
+type generation struct {
+	arrived int
+	broken  bool
+	done    chan struct{} // Closed once the round is over or broken
+}
+
+func newGeneration() *generation {
+	return &generation{done: make(chan struct{})}
+}
+
+// CyclicBarrier blocks parties goroutines until all of them have called
+// Await, then runs action and lets them all go. It can then be used
+// again by the next round of parties.
+type CyclicBarrier struct {
+	parties int
+	action  func()
+
+	mu  sync.Mutex
+	gen *generation
+}
+
+// NewCyclicBarrier returns a barrier for parties goroutines. The last
+// goroutine to arrive in each round runs action, if not nil, before any
+// of them leaves.
+func NewCyclicBarrier(parties int, action func()) *CyclicBarrier {
+	if parties <= 0 {
+		panic("barrier: parties must be positive")
+	}
+	return &CyclicBarrier{parties: parties, action: action, gen: newGeneration()}
+}
+
+func (b *CyclicBarrier) Parties() int {
+	return b.parties
+}
+
+// Waiting is the number of parties waiting in the current round
+func (b *CyclicBarrier) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gen.arrived
+}
+
+// Broken reports whether the current round is broken
+func (b *CyclicBarrier) Broken() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gen.broken
+}
+
+func (b *CyclicBarrier) Await() error {
+	return b.AwaitContext(context.Background())
+}
+
+// AwaitContext is Await, but gives up when ctx is done, which breaks the
+// barrier. The goroutine giving up gets ctx.Err(), and every other party
+// of the round gets ErrBroken, as does any later one until Reset.
+func (b *CyclicBarrier) AwaitContext(ctx context.Context) error {
+	b.mu.Lock()
+	g := b.gen
+	if g.broken {
+		b.mu.Unlock()
+		return ErrBroken
+	}
+	if err := ctx.Err(); err != nil {
+		b.breakLocked()
+		b.mu.Unlock()
+		return err
+	}
+
+	g.arrived++
+	if g.arrived == b.parties {
+		// Last to arrive: run the action, then start the next round.
+		// The lock keeps the next round out until the action is done.
+		defer b.mu.Unlock()
+		if b.action != nil {
+			ok := false
+			defer func() {
+				if !ok {
+					b.breakLocked() // The action panicked
+				}
+			}()
+			b.action()
+			ok = true
+		}
+		b.gen = newGeneration()
+		close(g.done)
+		return nil
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		select {
+		case <-g.done: // The round finished, or broke, first
+		default:
+			b.breakLocked()
+			return ctx.Err()
+		}
+	}
+	if g.broken {
+		return ErrBroken
+	}
+	return nil
+}
+
+// Reset breaks the current round, turning its parties away with
+// ErrBroken, and starts a new one
+func (b *CyclicBarrier) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.breakLocked()
+	b.gen = newGeneration()
+}
+
+// breakLocked breaks the current round, if it is not broken already
+func (b *CyclicBarrier) breakLocked() {
+	if !b.gen.broken {
+		b.gen.broken = true
+		close(b.gen.done)
+	}
+}
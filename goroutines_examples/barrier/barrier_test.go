@@ -0,0 +1,185 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// await runs wait in the background and returns the channel its result
+// will be sent on
+func await(wait func(context.Context) error, ctx context.Context) <-chan error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- wait(ctx) }()
+	return errCh
+}
+
+func result(t *testing.T, errCh <-chan error, what string) error {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(time.Second):
+		t.Fatalf("%s is still waiting", what)
+		return nil
+	}
+}
+
+// Water molecules out of a barrier: two hydrogen and one oxygen permits
+// let three atoms in, which meet at the barrier before bonding (precommit)
+// and again after bonding (postcommit) before letting the next ones in
+func TestCyclicBarrierWater(t *testing.T) {
+	const molecules = 30
+	hydrogens := make(chan struct{}, 2)
+	oxygens := make(chan struct{}, 1)
+
+	var mu sync.Mutex
+	var round []string
+	var bonding, rounds int
+	var bad []string
+	precommit := NewCyclicBarrier(3, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if bonding != 0 {
+			bad = append(bad, "round started while the last one was still bonding")
+		}
+		round = nil
+	})
+	postcommit := NewCyclicBarrier(3, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		h := 0
+		for _, kind := range round {
+			if kind == "H" {
+				h++
+			}
+		}
+		if len(round) != 3 || h != 2 {
+			bad = append(bad, "round bonded with the wrong atoms")
+		}
+		rounds++
+	})
+
+	atom := func(kind string, permits chan struct{}) {
+		permits <- struct{}{}
+		defer func() { <-permits }()
+		if err := precommit.Await(); err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		bonding++
+		round = append(round, kind)
+		mu.Unlock()
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+		mu.Lock()
+		bonding--
+		mu.Unlock()
+		if err := postcommit.Await(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3*molecules; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+			if i%3 == 0 {
+				atom("O", oxygens)
+			} else {
+				atom("H", hydrogens)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(bad) > 0 {
+		t.Fatal(bad[0])
+	}
+	if rounds != molecules {
+		t.Fatalf("%d rounds, want %d", rounds, molecules)
+	}
+}
+
+// A hydrogen gives up waiting for the third atom of its molecule, which
+// breaks the barrier for the oxygen already waiting
+func TestCyclicBarrierAbandoned(t *testing.T) {
+	b := NewCyclicBarrier(3, func() { t.Error("broken round ran the action") })
+	oxygen := await(b.AwaitContext, context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	hydrogen := await(b.AwaitContext, ctx)
+
+	if err := result(t, hydrogen, "abandoning hydrogen"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("abandoning hydrogen returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := result(t, oxygen, "oxygen"); !errors.Is(err, ErrBroken) {
+		t.Fatalf("waiting oxygen returned %v, want %v", err, ErrBroken)
+	}
+	if !b.Broken() {
+		t.Fatal("barrier not broken")
+	}
+	if err := b.Await(); !errors.Is(err, ErrBroken) {
+		t.Fatalf("late atom returned %v, want %v", err, ErrBroken)
+	}
+}
+
+func TestCyclicBarrierReset(t *testing.T) {
+	var trips atomic.Int32
+	b := NewCyclicBarrier(3, func() { trips.Add(1) })
+	waiting := await(b.AwaitContext, context.Background())
+	for b.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	b.Reset()
+	if err := result(t, waiting, "party"); !errors.Is(err, ErrBroken) {
+		t.Fatalf("party waiting across Reset returned %v, want %v", err, ErrBroken)
+	}
+	if b.Broken() || b.Waiting() != 0 {
+		t.Fatalf("after Reset: broken %v, %d waiting", b.Broken(), b.Waiting())
+	}
+
+	// The barrier is usable again, round after round
+	for round := 1; round <= 3; round++ {
+		waiting := []<-chan error{
+			await(b.AwaitContext, context.Background()),
+			await(b.AwaitContext, context.Background()),
+			await(b.AwaitContext, context.Background()),
+		}
+		for _, errCh := range waiting {
+			if err := result(t, errCh, "party"); err != nil {
+				t.Fatalf("round %d: party returned %v", round, err)
+			}
+		}
+		if n := trips.Load(); n != int32(round) {
+			t.Fatalf("action ran %d times in %d rounds", n, round)
+		}
+	}
+}
+
+func TestCyclicBarrierActionPanics(t *testing.T) {
+	b := NewCyclicBarrier(2, func() { panic("bond failed") })
+	waiting := await(b.AwaitContext, context.Background())
+	for b.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "bond failed" {
+				t.Fatalf("Await panicked with %v", r)
+			}
+		}()
+		b.Await()
+	}()
+	if err := result(t, waiting, "party"); !errors.Is(err, ErrBroken) {
+		t.Fatalf("party waiting on a panicking action returned %v, want %v", err, ErrBroken)
+	}
+}
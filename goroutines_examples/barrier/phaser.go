@@ -0,0 +1,204 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+)
+
+// Phaser is a cyclic barrier whose parties may change from one phase to
+// the next. Parties Register to take part, and the phase advances once
+// every registered party has arrived.
+type Phaser struct {
+	action func(phase int)
+
+	mu         sync.Mutex
+	phase      int
+	registered int
+	arrived    int
+	broken     bool
+	advance    chan struct{} // Closed once the phase advances or breaks
+}
+
+// NewPhaser returns a phaser with parties registered parties. Whenever
+// a phase is over, the last party to arrive runs action, if not nil, with
+// the number of that phase before the next one starts.
+func NewPhaser(parties int, action func(phase int)) *Phaser {
+	if parties < 0 {
+		panic("barrier: negative parties")
+	}
+	return &Phaser{action: action, registered: parties, advance: make(chan struct{})}
+}
+
+// Phase is the number of the current phase, starting from 0
+func (p *Phaser) Phase() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+// Registered is the number of parties taking part in the current phase
+func (p *Phaser) Registered() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.registered
+}
+
+// Arrived is the number of parties that arrived in the current phase
+func (p *Phaser) Arrived() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.arrived
+}
+
+// Broken reports whether a party gave up while others waited for it.
+// A broken phaser stays broken.
+func (p *Phaser) Broken() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.broken
+}
+
+// Register adds a party, which must arrive before the current phase can
+// advance, and returns the current phase
+func (p *Phaser) Register() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken {
+		return p.phase, ErrBroken
+	}
+	p.registered++
+	return p.phase, nil
+}
+
+// Arrive marks a party as arrived without waiting for the others, and
+// returns the phase it arrived in
+func (p *Phaser) Arrive() (int, error) {
+	phase, _, err := p.arrive(false)
+	return phase, err
+}
+
+// ArriveAndDeregister marks a party as arrived and removes it from later
+// phases. It returns the phase it arrived in.
+func (p *Phaser) ArriveAndDeregister() (int, error) {
+	phase, _, err := p.arrive(true)
+	return phase, err
+}
+
+func (p *Phaser) ArriveAndAwaitAdvance() (int, error) {
+	return p.ArriveAndAwaitAdvanceContext(context.Background())
+}
+
+// ArriveAndAwaitAdvanceContext marks a party as arrived and waits for the
+// others, returning the number of the next phase. A party that gives up
+// when ctx is done breaks the phaser: it gets ctx.Err() and every other
+// party gets ErrBroken.
+func (p *Phaser) ArriveAndAwaitAdvanceContext(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.breakLocked()
+		return p.phase, err
+	}
+	phase, advance, err := p.arrive(false)
+	if err != nil {
+		return phase, err
+	}
+
+	select {
+	case <-advance:
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		select {
+		case <-advance: // The phase advanced, or broke, first
+		default:
+			p.breakLocked()
+			return phase, ctx.Err()
+		}
+	}
+	return p.awaited(phase)
+}
+
+// AwaitAdvance waits for phase to be over and returns the number of the
+// current phase. It returns at once if phase is already over. Unlike
+// parties, a goroutine giving up here does not break the phaser.
+func (p *Phaser) AwaitAdvance(ctx context.Context, phase int) (int, error) {
+	p.mu.Lock()
+	current, advance, broken := p.phase, p.advance, p.broken
+	p.mu.Unlock()
+	if broken {
+		return current, ErrBroken
+	}
+	if current != phase {
+		return current, nil
+	}
+
+	select {
+	case <-advance:
+		return p.awaited(phase)
+	case <-ctx.Done():
+		return phase, ctx.Err()
+	}
+}
+
+// awaited returns what a goroutine woken after waiting for phase gets
+func (p *Phaser) awaited(phase int) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken && p.phase == phase {
+		return phase, ErrBroken
+	}
+	return phase + 1, nil
+}
+
+// arrive counts a party as arrived in the current phase, advancing it if
+// the party was the last. It returns the phase the party arrived in and
+// the channel closed once that phase is over.
+func (p *Phaser) arrive(deregister bool) (int, chan struct{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase, advance := p.phase, p.advance
+	if p.broken {
+		return phase, advance, ErrBroken
+	}
+	if p.arrived >= p.registered {
+		panic("barrier: more arrivals than registered parties")
+	}
+
+	if deregister {
+		p.registered--
+	} else {
+		p.arrived++
+	}
+	if p.arrived == p.registered {
+		p.advanceLocked()
+	}
+	return phase, advance, nil
+}
+
+// advanceLocked runs the action and starts the next phase. A panicking
+// action breaks the phaser.
+func (p *Phaser) advanceLocked() {
+	if p.action != nil {
+		ok := false
+		defer func() {
+			if !ok {
+				p.breakLocked()
+			}
+		}()
+		p.action(p.phase)
+		ok = true
+	}
+	p.phase++
+	p.arrived = 0
+	close(p.advance)
+	p.advance = make(chan struct{})
+}
+
+// breakLocked breaks the phaser, if it is not broken already
+func (p *Phaser) breakLocked() {
+	if !p.broken {
+		p.broken = true
+		close(p.advance)
+	}
+}
@@ -0,0 +1,169 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// arriveAndAwait runs ArriveAndAwaitAdvanceContext in the background and
+// returns the channel its error will be sent on
+func arriveAndAwait(p *Phaser, ctx context.Context) <-chan error {
+	return await(func(ctx context.Context) error {
+		_, err := p.ArriveAndAwaitAdvanceContext(ctx)
+		return err
+	}, ctx)
+}
+
+// Atoms join and leave a molecule between phases: water, then hydrogen
+// peroxide once another oxygen registers, then molecular oxygen once the
+// hydrogens leave
+func TestPhaserMolecules(t *testing.T) {
+	var mu sync.Mutex
+	var molecules []string
+	var molecule string
+	p := NewPhaser(0, func(phase int) {
+		mu.Lock()
+		molecules = append(molecules, molecule)
+		molecule = ""
+		mu.Unlock()
+	})
+
+	// Each atom takes part in phases phases from first, leaving in the
+	// last one if leave. Atoms wait for joined before phase 1.
+	joined := make(chan struct{})
+	var wg sync.WaitGroup
+	atom := func(kind string, first, phases int, leave bool) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := first; i < first+phases; i++ {
+				if i == 1 {
+					<-joined
+				}
+				mu.Lock()
+				molecule += kind
+				mu.Unlock()
+				if i == first+phases-1 && leave {
+					if _, err := p.ArriveAndDeregister(); err != nil {
+						t.Error(err)
+					}
+					return
+				}
+				if _, err := p.ArriveAndAwaitAdvance(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	p.Register()
+	p.Register()
+	p.Register()
+	atom("H", 0, 2, true)
+	atom("H", 0, 2, true)
+	atom("O", 0, 3, true)
+	for p.Phase() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	p.Register()
+	atom("O", 1, 2, false)
+	close(joined)
+	wg.Wait()
+
+	if len(molecules) != 3 || !sameAtoms(molecules[0], "HHO") || !sameAtoms(molecules[1], "HHOO") || molecules[2] != "OO" {
+		t.Fatalf("phases bonded %q, want water, hydrogen peroxide and oxygen", molecules)
+	}
+	if p.Phase() != 3 || p.Registered() != 1 {
+		t.Fatalf("ended in phase %d with %d parties, want phase 3 with 1", p.Phase(), p.Registered())
+	}
+}
+
+// sameAtoms reports whether molecules a and b have the same atoms
+func sameAtoms(a, b string) bool {
+	count := func(s string) (h, o int) {
+		return strings.Count(s, "H"), strings.Count(s, "O")
+	}
+	ah, ao := count(a)
+	bh, bo := count(b)
+	return len(a) == len(b) && ah == bh && ao == bo
+}
+
+func TestPhaserAbandoned(t *testing.T) {
+	p := NewPhaser(3, nil)
+	oxygen := arriveAndAwait(p, context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	hydrogen := arriveAndAwait(p, ctx)
+
+	if err := result(t, hydrogen, "abandoning hydrogen"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("abandoning hydrogen returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := result(t, oxygen, "oxygen"); !errors.Is(err, ErrBroken) {
+		t.Fatalf("waiting oxygen returned %v, want %v", err, ErrBroken)
+	}
+	if !p.Broken() {
+		t.Fatal("phaser not broken")
+	}
+	if _, err := p.Register(); !errors.Is(err, ErrBroken) {
+		t.Fatalf("Register returned %v, want %v", err, ErrBroken)
+	}
+	if _, err := p.Arrive(); !errors.Is(err, ErrBroken) {
+		t.Fatalf("late atom returned %v, want %v", err, ErrBroken)
+	}
+}
+
+func TestPhaserAwaitAdvance(t *testing.T) {
+	p := NewPhaser(2, nil)
+	advanced := make(chan int, 1)
+	go func() {
+		phase, err := p.AwaitAdvance(context.Background(), 0)
+		if err != nil {
+			t.Error(err)
+		}
+		advanced <- phase
+	}()
+
+	// An observer giving up does not break the phaser
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.AwaitAdvance(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AwaitAdvance returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if phase, err := p.Arrive(); phase != 0 || err != nil {
+		t.Fatalf("Arrive = %d, %v", phase, err)
+	}
+	select {
+	case <-advanced:
+		t.Fatal("phase advanced with a party missing")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.Arrive()
+	select {
+	case phase := <-advanced:
+		if phase != 1 {
+			t.Fatalf("AwaitAdvance returned phase %d, want 1", phase)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("observer not woken")
+	}
+	if phase, err := p.AwaitAdvance(context.Background(), 0); phase != 1 || err != nil {
+		t.Fatalf("AwaitAdvance of a past phase = %d, %v", phase, err)
+	}
+}
+
+func TestPhaserTooManyArrivals(t *testing.T) {
+	p := NewPhaser(1, nil)
+	p.ArriveAndDeregister()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("arriving without a registered party did not panic")
+		}
+	}()
+	p.Arrive()
+}
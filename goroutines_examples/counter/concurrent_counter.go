@@ -1,11 +1,14 @@
-package main
+// Package counter increments a shared count from many goroutines,
+// with and without synchronisation.
+package counter
 
 import (
 	"fmt"
 	"sync"
 )
 
-func main() {
+// Run prints the count reached by each of the counters
+func Run() {
 
 	goroutines_without_sync()
 	// goroutines_with_sync()
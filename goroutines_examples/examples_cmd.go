@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/counter"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/fanout"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/h2o"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/blocking"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/nonblocking"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/parallel"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/unbounded"
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/withcontext"
+)
+
+func runH2O(fs *flag.FlagSet, args []string, demo func(int, time.Duration, ...h2o.Option) h2o.Stats) error {
+	atoms := fs.Int("atoms", 33, "number of atoms, one in three oxygen on average")
+	duration := fs.Duration("duration", 5*time.Second, "how long to let atoms bond")
+	trace := fs.String("trace", "", "file to write a Chrome trace of the atoms to, for viewing in Perfetto")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := positive("atoms", *atoms); err != nil {
+		return err
+	}
+	if err := positiveDuration("duration", *duration); err != nil {
+		return err
+	}
+	if *trace == "" {
+		return printH2OStats(demo(*atoms, *duration))
+	}
+
+	f, err := os.Create(*trace)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tracer := h2o.NewTracer()
+	stats := demo(*atoms, *duration, h2o.WithTracer(tracer))
+	if err := tracer.WriteJSON(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return printH2OStats(stats)
+}
+
+// printH2OStats prints how long atoms of each kind waited for a molecule,
+// and how many never found one
+func printH2OStats(s h2o.Stats) error {
+	fmt.Printf("\n%d molecules in %v, %.1f per second, %d atoms unmatched\n",
+		s.Molecules, s.Elapsed.Round(time.Millisecond), s.MoleculesPerSecond(), s.Unmatched())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "atom\tarrived\tcommitted\tcancelled\tleftover\twaiting\tmean wait\tp99 wait\tmax wait\t")
+	for _, a := range []struct {
+		kind  string
+		stats h2o.AtomStats
+	}{{"H", s.Hydrogen}, {"O", s.Oxygen}} {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%v\t%v\t%v\t\n", a.kind,
+			a.stats.Arrived, a.stats.Committed, a.stats.Cancelled, a.stats.Leftover, a.stats.Waiting(),
+			a.stats.Wait.Mean().Round(time.Microsecond), a.stats.Wait.Quantile(0.99).Round(time.Microsecond),
+			a.stats.Wait.Max.Round(time.Microsecond))
+	}
+	return w.Flush()
+}
+
+func runH2ODaemon(fs *flag.FlagSet, args []string) error {
+	return runH2O(fs, args, h2o.DemoWaterFactoryWithDaemon)
+}
+
+func runH2OLeader(fs *flag.FlagSet, args []string) error {
+	return runH2O(fs, args, h2o.DemoWaterFactoryWithLeader)
+}
+
+// parseRecipe parses a recipe such as H:2,S:1,O:4
+func parseRecipe(s string) (map[string]int, error) {
+	recipe := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		kind, count, ok := strings.Cut(strings.TrimSpace(part), ":")
+		n, err := strconv.Atoi(count)
+		if !ok || kind == "" || err != nil || n <= 0 {
+			return nil, usagef("bad -recipe entry %q, want KIND:COUNT with a positive count", part)
+		}
+		recipe[kind] += n
+	}
+	return recipe, nil
+}
+
+func runH2OMolecule(fs *flag.FlagSet, args []string) error {
+	recipeFlag := fs.String("recipe", "H:2,S:1,O:4", "atoms of each kind in a molecule, as KIND:COUNT,...")
+	molecules := fs.Int("molecules", 3, "number of molecules to build")
+	duration := fs.Duration("duration", 5*time.Second, "how long to let atoms bond")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	recipe, err := parseRecipe(*recipeFlag)
+	if err != nil {
+		return err
+	}
+	if err := positive("molecules", *molecules); err != nil {
+		return err
+	}
+	if err := positiveDuration("duration", *duration); err != nil {
+		return err
+	}
+	h2o.DemoMoleculeFactory(recipe, *molecules, *duration)
+	return nil
+}
+
+// runQueue runs sum with the common queue flags. checks validate the flags
+// a command adds to fs, once they are parsed.
+func runQueue(fs *flag.FlagSet, args []string, producers int, consumers int, sum func(int, int, time.Duration) int, checks ...func() error) error {
+	fs.IntVar(&producers, "producers", producers, "number of producers")
+	fs.IntVar(&consumers, "consumers", consumers, "number of consumers")
+	duration := fs.Duration("duration", time.Second, "how long to run")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := positive("producers", producers); err != nil {
+		return err
+	}
+	if err := positive("consumers", consumers); err != nil {
+		return err
+	}
+	if err := positiveDuration("duration", *duration); err != nil {
+		return err
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Sum: ", sum(producers, consumers, *duration))
+	return nil
+}
+
+func runQueueBlocking(fs *flag.FlagSet, args []string) error {
+	return runQueue(fs, args, blocking.NumProducer, blocking.NumConsumer, blocking.Run)
+}
+
+func runQueueNonBlocking(fs *flag.FlagSet, args []string) error {
+	return runQueue(fs, args, nonblocking.NumProducer, nonblocking.NumConsumer, nonblocking.Run)
+}
+
+func runQueueContext(fs *flag.FlagSet, args []string) error {
+	return runQueue(fs, args, withcontext.NumProducer, withcontext.NumConsumer, withcontext.Run)
+}
+
+func runQueueParallel(fs *flag.FlagSet, args []string) error {
+	return runQueue(fs, args, parallel.NumProducer, parallel.NumConsumer, parallel.Run)
+}
+
+func runQueueUnbounded(fs *flag.FlagSet, args []string) error {
+	opts := unbounded.DefaultOptions()
+	fs.IntVar(&opts.Burst, "burst", opts.Burst, "numbers each producer sends at once")
+	fs.DurationVar(&opts.Pause, "pause", opts.Pause, "pause of producers between bursts")
+	fs.IntVar(&opts.HighWatermark, "watermark", opts.HighWatermark, "buffered numbers above which to warn that consumers lag")
+	run := func(producers int, consumers int, d time.Duration) int {
+		return unbounded.Run(producers, consumers, d, opts)
+	}
+	return runQueue(fs, args, unbounded.NumProducer, unbounded.NumConsumer, run,
+		func() error { return positive("burst", opts.Burst) },
+		func() error { return positiveDuration("pause", opts.Pause) },
+		func() error { return positive("watermark", opts.HighWatermark) },
+	)
+}
+
+func runFanout(fs *flag.FlagSet, args []string) error {
+	events := fs.Int("events", 30, "number of events")
+	workers := fs.Int("workers", 10, "number of workers")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := positive("events", *events); err != nil {
+		return err
+	}
+	if err := positive("workers", *workers); err != nil {
+		return err
+	}
+	fanout.Run(*events, *workers)
+	return nil
+}
+
+func runCounter(fs *flag.FlagSet, args []string) error {
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	counter.Run()
+	return nil
+}
@@ -1,4 +1,6 @@
-package main
+// Package fanout spreads a stream of events over a pool of workers and
+// merges their results back into a single stream.
+package fanout
 
 import (
 	"fmt"
@@ -50,12 +52,11 @@ func (w *worker) start(
 	}()
 }
 
-func genEventsCh() chan Event {
+func genEventsCh(events int) chan Event {
 	outputCh := make(chan Event)
 	go func() {
 		counter := int64(1)
-		rand.Seed(time.Now().Unix())
-		for i := 0; i < 30; i++ {
+		for i := 0; i < events; i++ {
 			outputCh <- Event{
 				id:       counter,
 				procTime: time.Duration(rand.Intn(100)) * time.Millisecond,
@@ -67,15 +68,17 @@ func genEventsCh() chan Event {
 	return outputCh
 }
 
-func main() {
+// Run fans out events random events to workers workers, which sleep
+// for each event's processing time, and prints the IDs as they come out
+func Run(events int, workers int) {
 	done := make(chan struct{})
 	outputCh := make(chan Event, 1)
 
-	inputCh := genEventsCh()
+	inputCh := genEventsCh(events)
 
 	// Fan-out the stream of input to multiple workers
 	var wg sync.WaitGroup
-	for i := 0; i < 10; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		newWorker(inputCh, outputCh).
 			start(done, func(e Event) Event {
@@ -0,0 +1,3 @@
+module github.com/zzkzzzz/Go_practices/goroutines_examples
+
+go 1.22
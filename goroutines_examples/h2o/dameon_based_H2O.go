@@ -0,0 +1,121 @@
+package h2o
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// However, using a daemon has some downsides. Since the daemon runs on a goroutine and
+// has references to the water factory object, even if all other references to the water
+// factory are dropped, the water factory will never be cleaned up as the daemon is still
+// holding a reference to it. The daemon goroutine itself also does not go away and leaks.
+
+// We can somewhat remedy this by giving our water factory a Destroy method that shuts down
+// the daemon with a Context, but this forces the user to keep track of references to the water factory,
+// and to properly clean it up when the last reference is about to be dropped. This means that such a
+// water factory effectively needs manual memory management, despite Go being a garbage collected language!
+
+// WaterFactoryWithDaemon has such a Destroy method. Destroying the factory, or cancelling
+// the context given to NewFactoryWithDaemonContext, stops the daemon.
+
+// Manual cleanup is only needed because the daemon holds on to the factory, though.
+// So the factory users get is a thin handle around the state the daemon shares with
+// the atoms, and the daemon only references that state. When the last reference to
+// the handle is dropped, the garbage collector finds it unreachable, and a finalizer
+// stops the daemon, just as Destroy would.
+
+// Water is only one recipe of molecule, so the daemon is MoleculeFactory's,
+// with a recipe of two hydrogens and one oxygen.
+
+// ErrDestroyed is returned to atoms that arrive at, or are still waiting in,
+// a factory whose daemon has been stopped
+var ErrDestroyed = errors.New("h2o: factory destroyed")
+
+// Kinds of atoms in water, as named in traces
+const (
+	hydrogen = "H"
+	oxygen   = "O"
+)
+
+// WaterFactoryWithDaemon is the handle given to users. The daemon never
+// references it, so that it can be garbage collected.
+type WaterFactoryWithDaemon struct {
+	mf *MoleculeFactory[string]
+}
+
+func NewFactoryWithDaemon(opts ...Option) *WaterFactoryWithDaemon {
+	return NewFactoryWithDaemonContext(context.Background(), opts...)
+}
+
+// NewFactoryWithDaemonContext is NewFactoryWithDaemon, with a daemon that
+// also shuts down when ctx is done
+func NewFactoryWithDaemonContext(ctx context.Context, opts ...Option) *WaterFactoryWithDaemon {
+	recipe := map[string]int{hydrogen: 2, oxygen: 1}
+	return &WaterFactoryWithDaemon{newMoleculeFactory(ctx, "WaterFactoryWithDaemon", recipe, opts)}
+}
+
+func (wfd *WaterFactoryWithDaemon) Hydrogen(bond func()) {
+	wfd.mf.Arrive(hydrogen, bond)
+}
+
+func (wfd *WaterFactoryWithDaemon) Oxygen(bond func()) {
+	wfd.mf.Arrive(oxygen, bond)
+}
+
+// HydrogenContext is Hydrogen, but gives up when ctx is done, or with
+// ErrDestroyed when the factory is destroyed, before the atom is committed.
+// Once committed, the atom bonds and HydrogenContext returns nil.
+func (wfd *WaterFactoryWithDaemon) HydrogenContext(ctx context.Context, bond func()) error {
+	return wfd.mf.ArriveContext(ctx, hydrogen, bond)
+}
+
+// OxygenContext is Oxygen, but gives up like HydrogenContext
+func (wfd *WaterFactoryWithDaemon) OxygenContext(ctx context.Context, bond func()) error {
+	return wfd.mf.ArriveContext(ctx, oxygen, bond)
+}
+
+// Stats returns what the atoms went through so far. Elapsed stops
+// counting once the factory is destroyed.
+func (wfd *WaterFactoryWithDaemon) Stats() Stats {
+	return waterStats(wfd.mf.stats)
+}
+
+// Destroy stops the daemon and waits for it to exit. Atoms still waiting
+// in precommit return ErrDestroyed, as do atoms that arrive later.
+func (wfd *WaterFactoryWithDaemon) Destroy() {
+	wfd.mf.Destroy()
+}
+
+///////////////////////////////////////////////////////////////
+
+// DemoWaterFactoryWithDaemon sends atoms random atoms, one in three
+// oxygen, into a daemon-based factory and lets them bond for wait.
+// opts configure the factory, such as WithTracer. It returns the Stats of
+// the factory at the end.
+func DemoWaterFactoryWithDaemon(atoms int, wait time.Duration, opts ...Option) Stats {
+	oxygenBond := func() {
+		fmt.Println("Bonding oxygen")
+		time.Sleep(5 * time.Millisecond)
+		fmt.Println("Done")
+	}
+	hydrogenBond := func() {
+		fmt.Println("Bonding hydrogen")
+		time.Sleep(5 * time.Millisecond)
+		fmt.Println("Done")
+	}
+
+	wfd := NewFactoryWithDaemon(opts...)
+	for i := 0; i < atoms; i++ {
+		if rand.Intn(3) == 2 {
+			go wfd.OxygenContext(context.Background(), oxygenBond)
+		} else {
+			go wfd.HydrogenContext(context.Background(), hydrogenBond)
+		}
+	}
+	time.Sleep(wait)
+	wfd.Destroy() // Atoms left over are turned away
+	return wfd.Stats()
+}
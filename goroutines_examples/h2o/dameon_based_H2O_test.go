@@ -0,0 +1,183 @@
+package h2o
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// arrive starts an atom in the background and returns the channel its
+// result will be sent on
+func arrive(atom func(context.Context, func()) error, ctx context.Context, bond func()) <-chan error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- atom(ctx, bond) }()
+	time.Sleep(10 * time.Millisecond) // Let it reach precommit
+	return errCh
+}
+
+func result(t *testing.T, errCh <-chan error, what string) error {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(time.Second):
+		t.Fatalf("%s did not return", what)
+		return nil
+	}
+}
+
+func TestDaemonAtomAbandonsPrecommit(t *testing.T) {
+	wfd := NewFactoryWithDaemon()
+	defer wfd.Destroy()
+
+	var abandonedBonded atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := arrive(wfd.HydrogenContext, ctx, func() { abandonedBonded.Store(true) })
+
+	var bonded atomic.Int32
+	bond := func() { bonded.Add(1) }
+	o := arrive(wfd.OxygenContext, context.Background(), bond)
+
+	// The first hydrogen leaves the partial molecule
+	cancel()
+	if err := result(t, abandoned, "abandoning hydrogen"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("abandoning hydrogen returned %v, want %v", err, context.Canceled)
+	}
+
+	// and two new ones complete the molecule
+	h1 := arrive(wfd.HydrogenContext, context.Background(), bond)
+	h2 := arrive(wfd.HydrogenContext, context.Background(), bond)
+	for _, errCh := range []<-chan error{o, h1, h2} {
+		if err := result(t, errCh, "atom"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bonded.Load() != 3 || abandonedBonded.Load() {
+		t.Fatalf("%d atoms bonded, abandoned atom bonded: %v", bonded.Load(), abandonedBonded.Load())
+	}
+}
+
+func TestDaemonDestroy(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	wfd := NewFactoryWithDaemon()
+	waiting := []<-chan error{
+		arrive(wfd.HydrogenContext, context.Background(), func() {}),
+		arrive(wfd.OxygenContext, context.Background(), func() {}),
+	}
+
+	wfd.Destroy()
+	for _, errCh := range waiting {
+		if err := result(t, errCh, "waiting atom"); !errors.Is(err, ErrDestroyed) {
+			t.Fatalf("waiting atom returned %v, want %v", err, ErrDestroyed)
+		}
+	}
+	if err := wfd.HydrogenContext(context.Background(), func() {}); !errors.Is(err, ErrDestroyed) {
+		t.Fatalf("atom arriving after Destroy returned %v, want %v", err, ErrDestroyed)
+	}
+	wfd.Destroy() // Destroying twice is harmless
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Destroy, want %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	defer func() {
+		if r := recover(); r != ErrDestroyed {
+			t.Fatalf("Oxygen panicked with %v, want %v", r, ErrDestroyed)
+		}
+	}()
+	wfd.Oxygen(func() {})
+}
+
+func TestDaemonContextShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wfd := NewFactoryWithDaemonContext(ctx)
+	h := arrive(wfd.HydrogenContext, context.Background(), func() {})
+	cancel()
+	if err := result(t, h, "waiting atom"); !errors.Is(err, ErrDestroyed) {
+		t.Fatalf("waiting atom returned %v, want %v", err, ErrDestroyed)
+	}
+}
+
+func TestDaemonCancelRacingCommit(t *testing.T) {
+	wfd := NewFactoryWithDaemon()
+	defer wfd.Destroy()
+	for i := 0; i < 200; i++ {
+		var bonded atomic.Int32
+		bond := func() { bonded.Add(1) }
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 3)
+		go func() { errCh <- wfd.HydrogenContext(ctx, bond) }()
+		go func() { errCh <- wfd.HydrogenContext(context.Background(), bond) }()
+		go func() { errCh <- wfd.OxygenContext(context.Background(), bond) }()
+		go cancel()
+
+		// Either the molecule bonded whole, or the hydrogen left and
+		// the other two are still waiting for a partner
+		if err := <-errCh; err != nil {
+			wfd.Hydrogen(bond)
+			<-errCh
+			<-errCh
+		} else {
+			<-errCh
+			<-errCh
+		}
+		if n := bonded.Load(); n != 3 {
+			t.Fatalf("round %d: %d atoms bonded, want 3", i, n)
+		}
+	}
+}
+
+func TestDaemonDroppedFactoryStops(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		wfd := NewFactoryWithDaemon()
+		var wg sync.WaitGroup
+		for _, atom := range []func(func()){wfd.Hydrogen, wfd.Hydrogen, wfd.Oxygen} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				atom(func() {})
+			}()
+		}
+		wg.Wait()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after dropping the factories, want %d", runtime.NumGoroutine(), baseline)
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// An atom waiting in precommit keeps the factory alive, even once its user
+// has dropped it
+func TestDaemonWaitingAtomKeepsFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := arrive(NewFactoryWithDaemon().HydrogenContext, ctx, func() {})
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("atom returned %v while still waiting", err)
+	default:
+	}
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("HydrogenContext = %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package h2o
 
 import (
 	"fmt"
@@ -10,6 +10,10 @@ type WaterFactoryWithLeader struct {
 	oxygenMutex chan struct{}
 	precomH     chan chan struct{}
 	commit      chan chan struct{}
+
+	trace      *factoryTrace
+	stats      *factoryStats[string]
+	postcommit func() // See options
 }
 
 //  Using oxygen atoms as leader goroutines
@@ -18,27 +22,43 @@ type WaterFactoryWithLeader struct {
 
 // To ensure that there’s never two leaders active at the same time, we can simply use a mutex.
 
-func NewFactoryWithLeader() WaterFactoryWithLeader {
+func NewFactoryWithLeader(opts ...Option) WaterFactoryWithLeader {
+	o := newOptions(opts)
 	wf := WaterFactoryWithLeader{
 		oxygenMutex: make(chan struct{}, 1),
 		precomH:     make(chan chan struct{}),
 		commit:      make(chan chan struct{}),
+		trace:       o.tracer.factory("WaterFactoryWithLeader", 3),
+		stats:       newFactoryStats[string](),
+		postcommit:  o.postcommit,
 	}
 	wf.oxygenMutex <- struct{}{}
 	return wf
 }
 
-func (wf *WaterFactoryWithLeader) hydrogen(bond func()) {
+func (wf *WaterFactoryWithLeader) Hydrogen(bond func()) {
+	trace := wf.trace.atom(hydrogen)
+	arrived := time.Now()
+	wf.stats.arrive(hydrogen)
 	commit := make(chan struct{}) // Step 1: Create private communication channel
 	wf.precomH <- commit          // Step 2: (Precommit)
 	<-commit                      // Step 3: (Commit)
-	bond()                        // Step 4: Bond
-	commit <- struct{}{}          // Step 5: (Postcommit)
+	wf.stats.commit(hydrogen, arrived)
+	trace.commit()
+	bond() // Step 4: Bond
+	trace.bond()
+	commit <- struct{}{} // Step 5: (Postcommit)
+	trace.postcommit()
 }
 
-func (wf *WaterFactoryWithLeader) oxygen(bond func()) {
+func (wf *WaterFactoryWithLeader) Oxygen(bond func()) {
+	trace := wf.trace.atom(oxygen)
+	arrived := time.Now()
+	wf.stats.arrive(oxygen)
+
 	// Step 1: Become leader
 	<-wf.oxygenMutex // For fun, we can use a channel as a mutex
+	trace.lead()
 
 	// Step 2: (Precommit)
 	//         Receive arrival requets from 2 hydrogen atoms
@@ -49,21 +69,42 @@ func (wf *WaterFactoryWithLeader) oxygen(bond func()) {
 	//         Tell the 2 hydrogen atoms to start bonding
 	h1 <- struct{}{}
 	h2 <- struct{}{}
+	wf.stats.commit(oxygen, arrived)
+	wf.stats.molecule()
+	trace.commit()
 
 	// Step 4: Bond
 	bond()
+	trace.bond()
 
 	// Step 5: (Postcommit)
 	//         Wait until the 2 hydrogen atoms to finish
 	// We re-use the same communication channel as (Commit)
 	<-h1
 	<-h2
+	trace.postcommit()
+	if wf.postcommit != nil {
+		wf.postcommit()
+	}
 
 	// Step 6: Step down from being leader
+	trace.stepDown()
 	wf.oxygenMutex <- struct{}{}
 }
 
-func TestWaterFactoryWithLeader() {
+// Stats returns what the atoms went through so far. The factory never
+// shuts down, so atoms that never found a molecule are still waiting.
+func (wf *WaterFactoryWithLeader) Stats() Stats {
+	return waterStats(wf.stats)
+}
+
+///////////////////////////////////////////////////////////////
+
+// DemoWaterFactoryWithLeader sends atoms random atoms, one in three
+// oxygen, into a leader-based factory and lets them bond for wait.
+// opts configure the factory, such as WithTracer. It returns the Stats of
+// the factory at the end.
+func DemoWaterFactoryWithLeader(atoms int, wait time.Duration, opts ...Option) Stats {
 	oxygenBond := func() {
 		fmt.Println("Bonding oxygen")
 		time.Sleep(5 * time.Millisecond)
@@ -75,15 +116,14 @@ func TestWaterFactoryWithLeader() {
 		fmt.Println("Done")
 	}
 
-	wf := NewFactoryWithLeader()
-	for i := 0; i < 33; i++ {
+	wf := NewFactoryWithLeader(opts...)
+	for i := 0; i < atoms; i++ {
 		if rand.Intn(3) == 2 {
-			go wf.oxygen(oxygenBond)
+			go wf.Oxygen(oxygenBond)
 		} else {
-			go wf.hydrogen(hydrogenBond)
+			go wf.Hydrogen(hydrogenBond)
 		}
 	}
-	time.Sleep(5 * time.Second)
+	time.Sleep(wait)
+	return wf.Stats()
 }
-
-func main() { TestWaterFactoryWithLeader() }
@@ -0,0 +1,15 @@
+// Package h2o builds water molecules out of hydrogen and oxygen goroutines.
+//
+// Each atom calls Hydrogen or Oxygen with a bond function, and atoms are
+// released in groups of two hydrogens and one oxygen that bond together.
+// WaterFactoryWithDaemon groups atoms with a daemon goroutine, while
+// WaterFactoryWithLeader lets an oxygen atom lead its own molecule.
+//
+// MoleculeFactory runs the daemon's protocol for any recipe of atoms, and
+// WaterFactoryWithDaemon is a MoleculeFactory for water.
+//
+// A Tracer given to the factories with WithTracer records what each atom
+// goes through, as a trace that can be viewed in Perfetto. The Stats of
+// the water factories tell how long atoms waited and how many never found
+// a molecule.
+package h2o
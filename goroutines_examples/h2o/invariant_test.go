@@ -0,0 +1,226 @@
+package h2o
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Phases of a round that bondEvents record
+const (
+	bondStart  = iota // An atom starts bonding
+	bondEnd           // An atom finishes bonding
+	postcommit        // The factory is done with the postcommit of the round
+)
+
+// bondEvent is an atom starting or finishing its bond, or the end of the
+// postcommit of a round, which is not about any atom in particular
+type bondEvent struct {
+	atom  int
+	kind  string // "H" or "O"
+	phase int
+}
+
+// bondLog records bond events in the order they happen
+type bondLog struct {
+	mu     sync.Mutex
+	events []bondEvent
+}
+
+func (l *bondLog) record(e bondEvent) {
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	l.mu.Unlock()
+}
+
+// bond returns the bond function of an atom, which holds the bond for d
+func (l *bondLog) bond(atom int, kind string, d time.Duration) func() {
+	return func() {
+		l.record(bondEvent{atom, kind, bondStart})
+		time.Sleep(d)
+		l.record(bondEvent{atom, kind, bondEnd})
+	}
+}
+
+// postcommitted records the end of the postcommit of a round
+func (l *bondLog) postcommitted() {
+	l.record(bondEvent{atom: -1, phase: postcommit})
+}
+
+// withPostcommit has a factory call f at the end of the postcommit of
+// each molecule
+func withPostcommit(f func()) Option {
+	return func(o *options) { o.postcommit = f }
+}
+
+// checkRounds splits events into bonding rounds and checks that each round
+// is two hydrogens and one oxygen, that its postcommit ends only once all
+// three have finished bonding, and that no atom starts bonding before the
+// postcommit of the previous round is over. It returns the atoms of each
+// round.
+func checkRounds(events []bondEvent) ([][]int, error) {
+	var rounds [][]int
+	var kinds []string
+	finished := 0
+	over := false // The postcommit of the last round is over
+	describe := func() string {
+		return fmt.Sprintf("round %d %v", len(rounds), rounds[len(rounds)-1])
+	}
+	for _, e := range events {
+		switch e.phase {
+		case bondStart:
+			if len(rounds) == 0 || over {
+				// Only once the previous round is over may a new one start
+				rounds = append(rounds, nil)
+				kinds, finished, over = nil, 0, false
+			} else if len(kinds) == 3 {
+				return rounds, fmt.Errorf("atom %d started bonding before the postcommit of %s", e.atom, describe())
+			}
+			rounds[len(rounds)-1] = append(rounds[len(rounds)-1], e.atom)
+			kinds = append(kinds, e.kind)
+
+		case bondEnd:
+			finished++
+			if finished == 3 {
+				if h := strings.Count(strings.Join(kinds, ""), "H"); h != 2 || len(kinds) != 3 {
+					return rounds, fmt.Errorf("%s bonded %v, want two H and one O", describe(), kinds)
+				}
+			}
+
+		case postcommit:
+			if len(rounds) == 0 || over {
+				return rounds, fmt.Errorf("postcommit with no round bonding")
+			}
+			if finished != 3 {
+				return rounds, fmt.Errorf("postcommit of %s before its atoms finished bonding", describe())
+			}
+			over = true
+		}
+	}
+	if len(rounds) > 0 && !over {
+		return rounds, fmt.Errorf("%s never finished its postcommit", describe())
+	}
+	return rounds, nil
+}
+
+func TestCheckRounds(t *testing.T) {
+	start := func(atom int, kind string) bondEvent { return bondEvent{atom, kind, bondStart} }
+	end := func(atom int, kind string) bondEvent { return bondEvent{atom, kind, bondEnd} }
+	post := bondEvent{atom: -1, phase: postcommit}
+	tests := []struct {
+		name   string
+		events []bondEvent
+		ok     bool
+	}{
+		{"two rounds", []bondEvent{
+			start(0, "H"), start(1, "O"), end(0, "H"), start(2, "H"), end(2, "H"), end(1, "O"), post,
+			start(3, "O"), start(4, "H"), start(5, "H"), end(3, "O"), end(4, "H"), end(5, "H"), post,
+		}, true},
+		{"three hydrogens", []bondEvent{
+			start(0, "H"), start(1, "H"), start(2, "H"), end(0, "H"), end(1, "H"), end(2, "H"), post,
+		}, false},
+		{"next round while bonding", []bondEvent{
+			start(0, "H"), start(1, "H"), start(2, "O"), end(0, "H"), end(1, "H"),
+			start(3, "H"), end(2, "O"), post,
+		}, false},
+		{"next round before postcommit", []bondEvent{
+			start(0, "H"), start(1, "H"), start(2, "O"), end(0, "H"), end(1, "H"), end(2, "O"),
+			start(3, "H"), post,
+		}, false},
+		{"postcommit while bonding", []bondEvent{
+			start(0, "H"), start(1, "H"), start(2, "O"), end(0, "H"), end(1, "H"), post, end(2, "O"),
+		}, false},
+		{"round never finished", []bondEvent{
+			start(0, "H"), start(1, "H"), start(2, "O"), end(0, "H"), end(1, "H"), end(2, "O"),
+		}, false},
+	}
+	for _, tt := range tests {
+		if _, err := checkRounds(tt.events); (err == nil) != tt.ok {
+			t.Errorf("%s: checkRounds = %v", tt.name, err)
+		}
+	}
+}
+
+// waterFactory is implemented by both water factories
+type waterFactory interface {
+	Hydrogen(bond func())
+	Oxygen(bond func())
+}
+
+// testWaterInvariants sends the atoms of molecules molecules into a
+// factory made by newFactory in random order, with random arrival and
+// bonding times, then checks the rounds they bonded in
+func testWaterInvariants(t *testing.T, newFactory func(...Option) waterFactory, molecules int) {
+	seed := time.Now().UnixNano()
+	rng := rand.New(rand.NewSource(seed))
+	t.Logf("seed %d", seed)
+
+	kinds := make([]string, 0, 3*molecules)
+	for i := 0; i < molecules; i++ {
+		kinds = append(kinds, "H", "H", "O")
+	}
+	rng.Shuffle(len(kinds), func(i, j int) { kinds[i], kinds[j] = kinds[j], kinds[i] })
+
+	var log bondLog
+	wf := newFactory(withPostcommit(log.postcommitted))
+	var wg sync.WaitGroup
+	for i, kind := range kinds {
+		arrival := time.Duration(rng.Intn(1000)) * time.Microsecond
+		bond := log.bond(i, kind, time.Duration(rng.Intn(2000))*time.Microsecond)
+		atom := wf.Hydrogen
+		if kind == "O" {
+			atom = wf.Oxygen
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(arrival)
+			atom(bond)
+		}()
+	}
+	wg.Wait()
+
+	// The last postcommit may end after its atoms have returned
+	deadline := time.Now().Add(time.Second)
+	for {
+		log.mu.Lock()
+		n := len(log.events)
+		log.mu.Unlock()
+		if n == 2*len(kinds)+molecules || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	rounds, err := checkRounds(log.events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != molecules {
+		t.Fatalf("%d rounds, want %d", len(rounds), molecules)
+	}
+}
+
+func TestWaterFactoryInvariants(t *testing.T) {
+	factories := []struct {
+		name string
+		new  func(...Option) waterFactory
+	}{
+		{"daemon", func(opts ...Option) waterFactory { return NewFactoryWithDaemon(opts...) }},
+		{"leader", func(opts ...Option) waterFactory {
+			wf := NewFactoryWithLeader(opts...)
+			return &wf
+		}},
+	}
+	for _, f := range factories {
+		t.Run(f.name, func(t *testing.T) {
+			for i := 0; i < 5; i++ {
+				testWaterInvariants(t, f.new, 30)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package h2o
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/internal/cleanup"
+)
+
+// MoleculeFactory builds molecules of any recipe, and WaterFactoryWithDaemon
+// is one for water.
+// The recipe, such as {"H": 2, "S": 1, "O": 4} for sulfuric acid, says how
+// many atoms of each kind make up one molecule.
+//
+// A daemon goroutine runs the protocol:
+//
+//	Precommit:  receive arrival requests until every kind is complete
+//	Commit:     tell every atom of the molecule to start bonding
+//	Postcommit: wait until they have all finished before the next molecule
+//
+// Atoms of the next molecule keep waiting in precommit meanwhile, so atoms
+// of two molecules never bond at the same time.
+//
+// The daemon stops when the factory is destroyed, when the context it was
+// created with is done, or once users drop the last reference to the
+// factory. The daemon never references the factory itself, so that it can
+// be garbage collected.
+type MoleculeFactory[K comparable] struct {
+	*moleculeState[K]
+}
+
+// moleculeState is shared by the daemon and the atoms
+type moleculeState[K comparable] struct {
+	recipe map[K]int
+	size   int // Atoms in one molecule
+
+	// Channel for atoms to send their arrival and cancel requests
+	requests chan moleculeRequest[K]
+	// Channel for bonded atoms to report to the daemon (Postcommit)
+	postcom chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{} // Closed once the daemon has exited
+
+	trace      *factoryTrace
+	stats      *factoryStats[K]
+	postcommit func() // See options
+}
+
+// An atom sends all its requests through the same channel, with the
+// private channel it waits on, so the daemon sees them in order
+type moleculeRequest[K comparable] struct {
+	kind    K
+	cancel  bool // Otherwise the atom arrives
+	ch      chan struct{}
+	arrived time.Time // When the atom arrived, for Stats
+}
+
+// NewMoleculeFactory starts the daemon of a factory for recipe.
+// opts configure the factory, such as WithTracer.
+func NewMoleculeFactory[K comparable](recipe map[K]int, opts ...Option) *MoleculeFactory[K] {
+	return NewMoleculeFactoryContext(context.Background(), recipe, opts...)
+}
+
+// NewMoleculeFactoryContext is NewMoleculeFactory, with a daemon that also
+// shuts down when ctx is done
+func NewMoleculeFactoryContext[K comparable](ctx context.Context, recipe map[K]int, opts ...Option) *MoleculeFactory[K] {
+	return newMoleculeFactory(ctx, "MoleculeFactory", recipe, opts)
+}
+
+// newMoleculeFactory starts a factory called name in traces
+func newMoleculeFactory[K comparable](ctx context.Context, name string, recipe map[K]int, opts []Option) *MoleculeFactory[K] {
+	if len(recipe) == 0 {
+		panic("h2o: empty recipe")
+	}
+	o := newOptions(opts)
+	m := &moleculeState[K]{
+		recipe:   make(map[K]int, len(recipe)),
+		requests: make(chan moleculeRequest[K]),
+		done:     make(chan struct{}),
+		stats:    newFactoryStats[K](),
+	}
+	for kind, n := range recipe {
+		if n <= 0 {
+			panic(fmt.Sprintf("h2o: %d atoms of %v in recipe", n, kind))
+		}
+		m.recipe[kind] = n
+		m.size += n
+	}
+	m.postcom = make(chan struct{}, m.size)
+	m.trace = o.tracer.factory(name, m.size)
+	m.postcommit = o.postcommit
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	// Daemon goroutine
+	go func() {
+		// Atoms in precommit, by kind, in order of arrival. Their channels
+		// have a buffer of one, so the daemon never blocks on an atom: it
+		// sends on the channel to commit the atom, and closes it to turn
+		// it away.
+		waiting := make(map[K][]moleculeRequest[K], len(m.recipe))
+		bonding := 0 // Atoms of the current molecule yet to finish
+
+		for {
+			// Step 2: (Commit)
+			//         Tell the atoms of the recipe to start bonding,
+			//         once the previous molecule is done
+			if bonding == 0 && m.complete(waiting) {
+				for kind, n := range m.recipe {
+					for _, atom := range waiting[kind][:n] {
+						atom.ch <- struct{}{}
+						m.stats.commit(kind, atom.arrived)
+					}
+					waiting[kind] = waiting[kind][n:]
+				}
+				m.stats.molecule()
+				bonding = m.size
+			}
+
+			select {
+			// Step 1: (Precommit)
+			//         Receive arrival requests, and let atoms that are
+			//         still waiting leave without breaking up a molecule
+			case req := <-m.requests:
+				if !req.cancel {
+					waiting[req.kind] = append(waiting[req.kind], req)
+					m.stats.arrive(req.kind)
+					break
+				}
+				// If the atom was already committed, it finds the commit
+				// before the close and goes on to bond
+				n := len(waiting[req.kind])
+				waiting[req.kind] = slices.DeleteFunc(waiting[req.kind], func(atom moleculeRequest[K]) bool { return atom.ch == req.ch })
+				if len(waiting[req.kind]) < n {
+					m.stats.cancel(req.kind)
+				}
+				close(req.ch)
+
+			// Step 3: (Postcommit)
+			//         Wait until the atoms have finished before committing more
+			case <-m.postcom:
+				bonding--
+				if bonding == 0 && m.postcommit != nil {
+					m.postcommit()
+				}
+
+			case <-ctx.Done(): // Shut down
+				leftover := make(map[K]int, len(waiting))
+				for kind, atoms := range waiting {
+					for _, atom := range atoms {
+						close(atom.ch) // Turn the atom away
+					}
+					leftover[kind] = len(atoms)
+				}
+				m.stats.stop(leftover)
+				close(m.done)
+				return
+			}
+		}
+	}()
+
+	mf := &MoleculeFactory[K]{m}
+	cleanup.StopWhenUnreachable(mf, m.cancel)
+	return mf
+}
+
+// complete reports whether enough atoms of every kind are waiting to
+// make a molecule
+func (m *moleculeState[K]) complete(waiting map[K][]moleculeRequest[K]) bool {
+	for kind, n := range m.recipe {
+		if len(waiting[kind]) < n {
+			return false
+		}
+	}
+	return true
+}
+
+// Size is the number of atoms in one molecule
+func (mf *MoleculeFactory[K]) Size() int {
+	return mf.size
+}
+
+// Arrive blocks until a molecule can be formed with the atom, then calls
+// bond alongside the other atoms of the molecule. It panics if kind is
+// not part of the recipe, or with ErrDestroyed if the factory is destroyed
+// before the atom is committed.
+func (mf *MoleculeFactory[K]) Arrive(kind K, bond func()) {
+	if err := mf.ArriveContext(context.Background(), kind, bond); err != nil {
+		panic(err)
+	}
+}
+
+// ArriveContext is Arrive, but gives up when ctx is done, or with
+// ErrDestroyed when the factory is destroyed, before the atom is committed.
+// Once committed, the atom bonds and ArriveContext returns nil.
+func (mf *MoleculeFactory[K]) ArriveContext(ctx context.Context, kind K, bond func()) error {
+	defer runtime.KeepAlive(mf) // Don't stop the daemon while we wait
+	if _, ok := mf.recipe[kind]; !ok {
+		panic(fmt.Sprintf("h2o: %v is not part of the recipe", kind))
+	}
+	trace := mf.trace.atom(fmt.Sprint(kind))
+	commit := make(chan struct{}, 1) // Step 1: Create private communication channel
+	if err := mf.precommit(ctx, kind, commit); err != nil {
+		trace.abandon(err)
+		return err
+	}
+	trace.commit()
+
+	bond() // Step 4: Bond
+	trace.bond()
+	mf.postcom <- struct{}{} // Step 5: (Postcommit)
+	trace.postcommit()
+	return nil
+}
+
+// precommit sends the arrival request of an atom of kind waiting on
+// commit, and waits for the daemon to commit it
+func (mf *MoleculeFactory[K]) precommit(ctx context.Context, kind K, commit chan struct{}) error {
+	// Step 2: (Precommit)
+	select {
+	case mf.requests <- moleculeRequest[K]{kind: kind, ch: commit, arrived: time.Now()}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-mf.done:
+		return ErrDestroyed
+	}
+
+	// Step 3: (Commit)
+	if !mf.committed(ctx, kind, commit) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrDestroyed
+	}
+	return nil
+}
+
+// committed waits for the daemon to commit the atom of kind waiting on ch,
+// reporting whether it did. An atom that gives up asks the daemon to
+// forget it, and still bonds if the daemon committed it meanwhile.
+func (mf *MoleculeFactory[K]) committed(ctx context.Context, kind K, ch chan struct{}) bool {
+	select {
+	case _, ok := <-ch:
+		return ok
+	case <-mf.done:
+		return leftover(ch)
+	case <-ctx.Done():
+	}
+
+	select {
+	case mf.requests <- moleculeRequest[K]{kind: kind, cancel: true, ch: ch}:
+		// The daemon closes ch, after the commit if it sent one
+		_, ok := <-ch
+		return ok
+	case <-mf.done:
+		return leftover(ch)
+	}
+}
+
+// leftover reports whether a daemon that has exited committed the atom
+// waiting on ch. The daemon either committed the atom or closed ch before
+// exiting, so this never blocks.
+func leftover(ch chan struct{}) bool {
+	_, ok := <-ch
+	return ok
+}
+
+// Destroy stops the daemon and waits for it to exit. Atoms still waiting
+// in precommit return ErrDestroyed, as do atoms that arrive later.
+func (mf *MoleculeFactory[K]) Destroy() {
+	mf.cancel()
+	<-mf.done
+}
+
+///////////////////////////////////////////////////////////////
+
+// DemoMoleculeFactory sends the atoms of molecules molecules, in random
+// order, into a factory for recipe and lets them bond for wait
+func DemoMoleculeFactory(recipe map[string]int, molecules int, wait time.Duration) {
+	mf := NewMoleculeFactory(recipe)
+
+	var atoms []string
+	for kind, n := range recipe {
+		for i := 0; i < n*molecules; i++ {
+			atoms = append(atoms, kind)
+		}
+	}
+	rand.Shuffle(len(atoms), func(i, j int) { atoms[i], atoms[j] = atoms[j], atoms[i] })
+
+	for _, kind := range atoms {
+		go mf.ArriveContext(context.Background(), kind, func() {
+			fmt.Println("Bonding", kind)
+			time.Sleep(5 * time.Millisecond)
+			fmt.Println("Done")
+		})
+	}
+	time.Sleep(wait)
+	mf.Destroy() // Atoms left over are turned away
+}
@@ -0,0 +1,77 @@
+package h2o
+
+import (
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMoleculeFactory(t *testing.T) {
+	recipe := map[string]int{"H": 2, "S": 1, "O": 4}
+	const molecules = 20
+	mf := NewMoleculeFactory(recipe)
+	defer mf.Destroy()
+	size := mf.Size()
+
+	var mu sync.Mutex
+	var started, finished int
+	var group map[string]int
+	var bad []string
+	bond := func(kind string) func() {
+		return func() {
+			mu.Lock()
+			if started%size == 0 {
+				// First atom of the next molecule
+				if finished != started {
+					bad = append(bad, "atom of the next molecule bonded too early")
+				}
+				group = map[string]int{}
+			}
+			started++
+			group[kind]++
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			finished++
+			if finished%size == 0 && !maps.Equal(group, recipe) {
+				bad = append(bad, "molecule bonded with the wrong atoms")
+			}
+			mu.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < molecules; i++ {
+		for kind, n := range recipe {
+			for j := 0; j < n; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					mf.Arrive(kind, bond(kind))
+				}()
+			}
+		}
+	}
+	wg.Wait()
+
+	if finished != molecules*size {
+		t.Fatalf("%d atoms bonded, want %d", finished, molecules*size)
+	}
+	for _, msg := range bad {
+		t.Error(msg)
+	}
+}
+
+func TestMoleculeFactoryUnknownKind(t *testing.T) {
+	mf := NewMoleculeFactory(map[string]int{"H": 2, "O": 1})
+	defer mf.Destroy()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Arrive accepted an atom outside the recipe")
+		}
+	}()
+	mf.Arrive("N", func() {})
+}
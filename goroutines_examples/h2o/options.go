@@ -0,0 +1,19 @@
+package h2o
+
+// Option configures a factory
+type Option func(*options)
+
+type options struct {
+	tracer *Tracer
+	// Called once every atom of a molecule is done with postcommit, before
+	// the next molecule is committed. Only tests set it.
+	postcommit func()
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,133 @@
+package h2o
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// AtomStats is what the atoms of one kind went through in a factory
+type AtomStats struct {
+	Arrived   int
+	Committed int
+	Cancelled int // Gave up because their context was done
+	Leftover  int // Turned away when the factory shut down
+	// Time from arrival to commit of the committed atoms
+	Wait semaphore.Histogram
+}
+
+// Waiting is the number of atoms still waiting to be committed
+func (a *AtomStats) Waiting() int {
+	return a.Arrived - a.Committed - a.Cancelled - a.Leftover
+}
+
+// Stats compares how atoms fare in different factories
+type Stats struct {
+	Hydrogen  AtomStats
+	Oxygen    AtomStats
+	Molecules int
+	// Time from creating the factory to shutting it down, or to now if
+	// it is still running
+	Elapsed time.Duration
+}
+
+// MoleculesPerSecond is the rate at which the factory committed molecules
+func (s *Stats) MoleculesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Molecules) / s.Elapsed.Seconds()
+}
+
+// Unmatched is the number of atoms that never found a molecule: those
+// still waiting, and those turned away at shutdown
+func (s *Stats) Unmatched() int {
+	return s.Hydrogen.Waiting() + s.Hydrogen.Leftover + s.Oxygen.Waiting() + s.Oxygen.Leftover
+}
+
+// factoryStats collects what the atoms of a factory went through, by kind
+type factoryStats[K comparable] struct {
+	mu        sync.Mutex
+	start     time.Time
+	stopped   time.Time
+	atoms     map[K]*AtomStats
+	molecules int
+}
+
+func newFactoryStats[K comparable]() *factoryStats[K] {
+	return &factoryStats[K]{start: time.Now(), atoms: make(map[K]*AtomStats)}
+}
+
+// kind returns the stats of atoms of kind. The caller must hold f.mu.
+func (f *factoryStats[K]) kind(kind K) *AtomStats {
+	a := f.atoms[kind]
+	if a == nil {
+		a = new(AtomStats)
+		f.atoms[kind] = a
+	}
+	return a
+}
+
+// arrive counts an atom of kind arriving
+func (f *factoryStats[K]) arrive(kind K) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.kind(kind).Arrived++
+}
+
+// commit counts an atom of kind that arrived at arrived being committed
+func (f *factoryStats[K]) commit(kind K, arrived time.Time) {
+	wait := time.Since(arrived)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a := f.kind(kind)
+	a.Committed++
+	a.Wait.Record(wait)
+}
+
+// molecule counts a molecule whose atoms were all committed
+func (f *factoryStats[K]) molecule() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.molecules++
+}
+
+// cancel counts an atom of kind that gave up waiting
+func (f *factoryStats[K]) cancel(kind K) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.kind(kind).Cancelled++
+}
+
+// stop records that the factory shut down, turning away the atoms still
+// waiting, by kind
+func (f *factoryStats[K]) stop(leftover map[K]int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = time.Now()
+	for kind, n := range leftover {
+		f.kind(kind).Leftover = n
+	}
+}
+
+// snapshot returns a copy of the stats of each kind of atom, the number
+// of molecules and the time elapsed
+func (f *factoryStats[K]) snapshot() (map[K]AtomStats, int, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	atoms := make(map[K]AtomStats, len(f.atoms))
+	for kind, a := range f.atoms {
+		atoms[kind] = *a
+	}
+	if f.stopped.IsZero() {
+		return atoms, f.molecules, time.Since(f.start)
+	}
+	return atoms, f.molecules, f.stopped.Sub(f.start)
+}
+
+// waterStats returns the Stats of a water factory
+func waterStats(f *factoryStats[string]) Stats {
+	atoms, molecules, elapsed := f.snapshot()
+	return Stats{Hydrogen: atoms[hydrogen], Oxygen: atoms[oxygen], Molecules: molecules, Elapsed: elapsed}
+}
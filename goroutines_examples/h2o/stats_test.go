@@ -0,0 +1,75 @@
+package h2o
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// checkCommitted checks the stats of a factory that bonded molecules
+// molecules
+func checkCommitted(t *testing.T, s Stats, molecules int) {
+	t.Helper()
+	if s.Molecules != molecules || s.Hydrogen.Committed != 2*molecules || s.Oxygen.Committed != molecules {
+		t.Fatalf("%d molecules of %d H and %d O, want %d", s.Molecules, s.Hydrogen.Committed, s.Oxygen.Committed, molecules)
+	}
+	if n := s.Hydrogen.Wait.Count + s.Oxygen.Wait.Count; n != uint64(3*molecules) {
+		t.Fatalf("%d waits recorded, want %d", n, 3*molecules)
+	}
+	if s.Elapsed <= 0 || s.MoleculesPerSecond() <= 0 {
+		t.Fatalf("%v molecules per second over %v", s.MoleculesPerSecond(), s.Elapsed)
+	}
+}
+
+func TestStatsDaemon(t *testing.T) {
+	wfd := NewFactoryWithDaemon()
+	bondAll(wfd, 5)
+
+	// One hydrogen waits, another gives up
+	waiting := arrive(wfd.HydrogenContext, context.Background(), func() {})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := wfd.HydrogenContext(ctx, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("lone hydrogen returned %v", err)
+	}
+	s := wfd.Stats()
+	checkCommitted(t, s, 5)
+	if s.Hydrogen.Arrived != 12 || s.Hydrogen.Cancelled != 1 || s.Hydrogen.Waiting() != 1 {
+		t.Fatalf("hydrogens: %d arrived, %d cancelled, %d waiting, want 12, 1 and 1",
+			s.Hydrogen.Arrived, s.Hydrogen.Cancelled, s.Hydrogen.Waiting())
+	}
+
+	// The waiting hydrogen is left over at shutdown
+	wfd.Destroy()
+	result(t, waiting, "waiting hydrogen")
+	s = wfd.Stats()
+	if s.Hydrogen.Leftover != 1 || s.Hydrogen.Waiting() != 0 || s.Unmatched() != 1 {
+		t.Fatalf("after Destroy: %d left over, %d waiting, %d unmatched, want 1, 0 and 1",
+			s.Hydrogen.Leftover, s.Hydrogen.Waiting(), s.Unmatched())
+	}
+	time.Sleep(time.Millisecond)
+	if later := wfd.Stats(); later.Elapsed != s.Elapsed {
+		t.Fatalf("Elapsed went on from %v to %v after Destroy", s.Elapsed, later.Elapsed)
+	}
+}
+
+func TestStatsLeader(t *testing.T) {
+	wf := NewFactoryWithLeader()
+	bondAll(&wf, 5)
+
+	// A lone hydrogen waits forever
+	go wf.Hydrogen(func() {})
+	deadline := time.Now().Add(time.Second)
+	for wf.Stats().Hydrogen.Arrived != 11 {
+		if time.Now().After(deadline) {
+			t.Fatal("lone hydrogen never arrived")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s := wf.Stats()
+	checkCommitted(t, s, 5)
+	if s.Hydrogen.Waiting() != 1 || s.Unmatched() != 1 {
+		t.Fatalf("%d hydrogens waiting, %d atoms unmatched, want 1 and 1", s.Hydrogen.Waiting(), s.Unmatched())
+	}
+}
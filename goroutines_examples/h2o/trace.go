@@ -0,0 +1,250 @@
+package h2o
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tracer records what the atoms of water factories go through, and writes
+// it in the Chrome trace event format, which Perfetto (ui.perfetto.dev)
+// and chrome://tracing display as a timeline.
+//
+// Each factory shows up as a process, with a track per atom and a track
+// of the molecules it built. An atom's track shows its precommit, bond
+// and postcommit. Oxygen leaders also show how long they led, with an
+// arrow from each leader to the next.
+type Tracer struct {
+	mu        sync.Mutex
+	start     time.Time
+	factories int
+	events    []traceEvent
+}
+
+// traceEvent is an event of the Chrome trace event format, with
+// timestamps and durations in microseconds
+type traceEvent struct {
+	Name string         `json:"name"`
+	Ph   string         `json:"ph"` // Phase: X for a slice, M for metadata, s and f for an arrow
+	Ts   float64        `json:"ts"`
+	Dur  float64        `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	ID   int            `json:"id,omitempty"`
+	Bp   string         `json:"bp,omitempty"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{start: time.Now()}
+}
+
+// WithTracer records what the atoms of a factory go through in t
+func WithTracer(t *Tracer) Option {
+	return func(o *options) { o.tracer = t }
+}
+
+// WriteJSON writes the events recorded so far as a JSON trace
+func (t *Tracer) WriteJSON(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{t.events, "ms"})
+}
+
+// now is the time since the tracer was created, in microseconds
+func (t *Tracer) now() float64 {
+	return float64(time.Since(t.start).Nanoseconds()) / 1e3
+}
+
+// metadata names process pid, or its thread tid if tid is not 0
+func (t *Tracer) metadata(pid, tid int, name string) {
+	kind := "process_name"
+	if tid != 0 {
+		kind = "thread_name"
+	}
+	t.events = append(t.events, traceEvent{Name: kind, Ph: "M", Pid: pid, Tid: tid, Args: map[string]any{"name": name}})
+}
+
+// factoryTrace is the part of a trace about one factory.
+// Its methods do nothing on a nil factoryTrace.
+type factoryTrace struct {
+	t    *Tracer
+	pid  int
+	size int // Atoms in a molecule
+
+	// Guarded by t.mu
+	atoms     int
+	molecules int                    // Molecules committed so far
+	bonding   map[int]*moleculeTrace // Molecules not yet done with postcommit
+
+	// Last leader to step down, for the arrow to the next
+	handoff    int
+	lastLeader int
+	steppedAt  float64
+}
+
+// moleculeTrace is a molecule whose atoms are not all done yet
+type moleculeTrace struct {
+	atoms    []string // Names of its atoms
+	started  float64  // When its first atom was committed
+	finished int      // Atoms done with postcommit
+}
+
+// factory starts the trace of a factory called name, which builds
+// molecules of size atoms
+func (t *Tracer) factory(name string, size int) *factoryTrace {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.factories++
+	f := &factoryTrace{t: t, pid: t.factories, size: size, bonding: make(map[int]*moleculeTrace)}
+	t.metadata(f.pid, 0, name)
+	t.metadata(f.pid, 1, "molecules")
+	return f
+}
+
+// atomTrace is the track of one atom.
+// Its methods do nothing on a nil atomTrace.
+type atomTrace struct {
+	f        *factoryTrace
+	tid      int
+	name     string
+	molecule int
+
+	arrived   float64
+	precommit float64 // When precommit started, after leading for leaders
+	led       float64
+	committed float64
+	bonded    float64
+}
+
+// atom starts the track of an atom of kind, arriving now
+func (f *factoryTrace) atom(kind string) *atomTrace {
+	if f == nil {
+		return nil
+	}
+	t := f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f.atoms++
+	a := &atomTrace{f: f, tid: f.atoms + 1, name: kind + " " + strconv.Itoa(f.atoms), arrived: t.now()}
+	a.precommit = a.arrived
+	t.metadata(f.pid, a.tid, a.name)
+	return a
+}
+
+// slice records a slice of the atom's track from start to now
+func (a *atomTrace) slice(name string, start, now float64, args map[string]any) {
+	a.f.t.events = append(a.f.t.events, traceEvent{
+		Name: name, Ph: "X", Ts: start, Dur: now - start, Pid: a.f.pid, Tid: a.tid, Args: args,
+	})
+}
+
+// lead records that an oxygen atom became the leader
+func (a *atomTrace) lead() {
+	if a == nil {
+		return
+	}
+	t := a.f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a.led = t.now()
+	a.precommit = a.led
+	a.slice("wait to lead", a.arrived, a.led, nil)
+	if f := a.f; f.lastLeader != 0 {
+		// Arrow from the last leader stepping down to this one
+		f.handoff++
+		id := f.pid<<20 | f.handoff
+		t.events = append(t.events,
+			traceEvent{Name: "handoff", Ph: "s", Ts: f.steppedAt, Pid: f.pid, Tid: f.lastLeader, ID: id},
+			traceEvent{Name: "handoff", Ph: "f", Bp: "e", Ts: a.led, Pid: f.pid, Tid: a.tid, ID: id},
+		)
+	}
+}
+
+// stepDown records that a leader stepped down
+func (a *atomTrace) stepDown() {
+	if a == nil {
+		return
+	}
+	t := a.f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	a.slice("leader", a.led, now, map[string]any{"molecule": a.molecule})
+	a.f.lastLeader, a.f.steppedAt = a.tid, now
+}
+
+// commit records that the atom was committed to a molecule
+func (a *atomTrace) commit() {
+	if a == nil {
+		return
+	}
+	f, t := a.f, a.f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a.committed = t.now()
+	// Molecules never overlap, so the first atoms committed after a
+	// molecule is complete make up the next one
+	m := f.bonding[f.molecules]
+	if m == nil || len(m.atoms) == f.size {
+		f.molecules++
+		m = &moleculeTrace{started: a.committed}
+		f.bonding[f.molecules] = m
+	}
+	m.atoms = append(m.atoms, a.name)
+	a.molecule = f.molecules
+	a.slice("precommit", a.precommit, a.committed, nil)
+}
+
+// bond records that the atom finished bonding
+func (a *atomTrace) bond() {
+	if a == nil {
+		return
+	}
+	t := a.f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a.bonded = t.now()
+	a.slice("bond", a.committed, a.bonded, map[string]any{"molecule": a.molecule})
+}
+
+// postcommit records that the atom finished postcommit, and the molecule
+// once all its atoms have
+func (a *atomTrace) postcommit() {
+	if a == nil {
+		return
+	}
+	f, t := a.f, a.f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	a.slice("postcommit", a.bonded, now, nil)
+	m := f.bonding[a.molecule]
+	m.finished++
+	if m.finished == f.size {
+		delete(f.bonding, a.molecule)
+		t.events = append(t.events, traceEvent{
+			Name: "molecule " + strconv.Itoa(a.molecule), Ph: "X", Ts: m.started, Dur: now - m.started,
+			Pid: f.pid, Tid: 1, Args: map[string]any{"atoms": m.atoms},
+		})
+	}
+}
+
+// abandon records that the atom left precommit because of err
+func (a *atomTrace) abandon(err error) {
+	if a == nil {
+		return
+	}
+	t := a.f.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a.slice("precommit", a.precommit, t.now(), map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,139 @@
+package h2o
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readTrace writes the trace recorded by tr and decodes it back
+func readTrace(t *testing.T, tr *Tracer) []traceEvent {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tr.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("trace is not valid JSON: %v", err)
+	}
+	return trace.TraceEvents
+}
+
+// checkTrace checks that the trace of a factory shows molecules molecules
+// of two hydrogens and one oxygen, each atom going through every phase,
+// and returns the number of events of each name
+func checkTrace(t *testing.T, events []traceEvent, molecules int) map[string]int {
+	t.Helper()
+	count := make(map[string]int)
+	slices := make(map[int][]string) // Names of the slices of each track
+	for _, e := range events {
+		count[e.Name]++
+		if e.Ph == "X" {
+			slices[e.Tid] = append(slices[e.Tid], e.Name)
+			if e.Dur < 0 {
+				t.Errorf("%s slice of track %d lasts %v", e.Name, e.Tid, e.Dur)
+			}
+		}
+		if e.Tid == 1 && e.Ph == "X" {
+			atoms, _ := e.Args["atoms"].([]any)
+			kinds := ""
+			for _, atom := range atoms {
+				kinds += strings.Fields(atom.(string))[0]
+			}
+			if len(kinds) != 3 || strings.Count(kinds, "H") != 2 {
+				t.Errorf("%s made of %v", e.Name, atoms)
+			}
+		}
+	}
+	if got := len(slices[1]); got != molecules {
+		t.Errorf("%d molecules in the trace, want %d", got, molecules)
+	}
+	for _, phase := range []string{"precommit", "bond", "postcommit"} {
+		if count[phase] != 3*molecules {
+			t.Errorf("%d %s slices, want %d", count[phase], phase, 3*molecules)
+		}
+	}
+	return count
+}
+
+// bondAll sends the atoms of molecules molecules into wf and waits for
+// them to bond
+func bondAll(wf waterFactory, molecules int) {
+	var wg sync.WaitGroup
+	for i := 0; i < 3*molecules; i++ {
+		atom := wf.Hydrogen
+		if i%3 == 0 {
+			atom = wf.Oxygen
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atom(func() { time.Sleep(time.Millisecond) })
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTraceDaemon(t *testing.T) {
+	tr := NewTracer()
+	wfd := NewFactoryWithDaemon(WithTracer(tr))
+	bondAll(wfd, 5)
+
+	// An atom giving up shows up with a precommit ending in an error
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := wfd.OxygenContext(ctx, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("lone oxygen returned %v", err)
+	}
+
+	events := readTrace(t, tr)
+	count := checkTrace(t, events[:len(events)-1], 5)
+	if count["thread_name"] != 1+3*5+1 {
+		t.Errorf("%d tracks, want one for the molecules and one for each atom", count["thread_name"])
+	}
+	last := events[len(events)-1]
+	if last.Name != "precommit" || last.Args["error"] != context.DeadlineExceeded.Error() {
+		t.Errorf("atom that gave up traced as %+v", last)
+	}
+}
+
+func TestTraceLeader(t *testing.T) {
+	tr := NewTracer()
+	wf := NewFactoryWithLeader(WithTracer(tr))
+	bondAll(&wf, 5)
+
+	count := checkTrace(t, readTrace(t, tr), 5)
+	if count["leader"] != 5 {
+		t.Errorf("%d leaders, want 5", count["leader"])
+	}
+	// An arrow, made of a start and a finish, from each leader to the next
+	if count["handoff"] != 2*4 {
+		t.Errorf("%d handoff events, want %d", count["handoff"], 2*4)
+	}
+}
+
+// Factories sharing a tracer show up as separate processes
+func TestTraceSharedTracer(t *testing.T) {
+	tr := NewTracer()
+	wf := NewFactoryWithLeader(WithTracer(tr))
+	bondAll(NewFactoryWithDaemon(WithTracer(tr)), 1)
+	bondAll(&wf, 1)
+
+	names := make(map[int]any)
+	for _, e := range readTrace(t, tr) {
+		if e.Name == "process_name" {
+			names[e.Pid] = e.Args["name"]
+		}
+	}
+	if len(names) != 2 || names[1] == names[2] {
+		t.Fatalf("factories traced as processes %v", names)
+	}
+}
@@ -0,0 +1,32 @@
+// Package cleanup stops daemon goroutines once nothing uses them anymore.
+//
+// A daemon goroutine keeps everything it references alive, so an object
+// whose daemon references the object itself is never garbage collected,
+// and its daemon leaks. The way out is to split the object in two:
+//
+//   - the state shared with the daemon, such as its channels, which the
+//     daemon may reference freely
+//   - a thin handle given to users, which points to the state but is
+//     never referenced by the daemon
+//
+// Once users drop the last reference to the handle, it becomes unreachable
+// even though the daemon is still running, and StopWhenUnreachable can
+// tell the daemon to exit.
+package cleanup
+
+import "runtime"
+
+// StopWhenUnreachable calls stop once handle has become unreachable.
+// stop must not reference handle, or handle never becomes unreachable;
+// it is typically the CancelFunc of the context the daemon watches.
+//
+// stop runs on the runtime's finalizer goroutine, at some point after a
+// garbage collection finds handle unreachable, so it must not block.
+//
+// A method blocked on the daemon does not keep handle alive by itself once
+// it has read the fields it needs, so it must end with runtime.KeepAlive on
+// handle. Otherwise the last user may drop handle while the method waits,
+// and the daemon is stopped under it.
+func StopWhenUnreachable[T any](handle *T, stop func()) {
+	runtime.SetFinalizer(handle, func(*T) { stop() })
+}
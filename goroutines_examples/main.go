@@ -0,0 +1,134 @@
+// Command goroutines_examples runs the scenarios in this module.
+//
+//	goroutines_examples <command> [subcommand] [flags]
+//
+// Run it without arguments for the list of commands, and pass -h to a
+// command for its flags. Invalid input exits with status 2, and a failed
+// check, such as a FIFO violation, with status 1.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type command struct {
+	name    string // One or two words, such as "semaphore stress"
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"semaphore stress", "hammer semaphores with releasers and acquirers", runSemaphoreStress},
+	{"semaphore fifo", "verify that semaphores unblock waiters in FIFO order", runSemaphoreFIFO},
+	{"rwlock stress", "measure reader and writer starvation under each lock policy", runRWLockStress},
+	{"h2o daemon", "build water molecules with a daemon goroutine", runH2ODaemon},
+	{"h2o leader", "build water molecules led by oxygen atoms", runH2OLeader},
+	{"h2o molecule", "build molecules of any recipe with a daemon goroutine", runH2OMolecule},
+	{"queue blocking", "producers and consumers on a blocking queue", runQueueBlocking},
+	{"queue nonblocking", "producers and consumers on a non-blocking queue", runQueueNonBlocking},
+	{"queue context", "producers and consumers stopped with a context", runQueueContext},
+	{"queue parallel", "producers and consumers with local sums", runQueueParallel},
+	{"queue unbounded", "bursty producers on an unbounded channel that warns when consumers lag", runQueueUnbounded},
+	{"fanout", "fan events out to workers and back in", runFanout},
+	{"counter", "increment a counter from many goroutines", runCounter},
+}
+
+// usageError marks errors caused by invalid input
+type usageError struct {
+	error
+	reported bool // Already printed by the flag package
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{error: fmt.Errorf(format, args...)}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: goroutines_examples <command> [subcommand] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-20s %s\n", c.name, c.summary)
+	}
+}
+
+// lookup finds the command named by the first one or two arguments
+func lookup(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return usageError{error: errors.New("no command given")}
+	}
+	c, rest := lookup(args)
+	if c == nil {
+		usage(os.Stderr)
+		return usagef("unknown command %q", strings.Join(args, " "))
+	}
+
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: goroutines_examples %s [flags]\n\n%s\n\nflags:\n", c.name, c.summary)
+		fs.PrintDefaults()
+	}
+	return c.run(fs, rest)
+}
+
+// parse parses args into fs, rejecting positional arguments
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{err, true}
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+func positive(name string, v int) error {
+	if v <= 0 {
+		return usagef("-%s must be positive, got %d", name, v)
+	}
+	return nil
+}
+
+func positiveDuration(name string, d time.Duration) error {
+	if d <= 0 {
+		return usagef("-%s must be positive, got %v", name, d)
+	}
+	return nil
+}
+
+func main() {
+	err := run(os.Args[1:])
+	var uerr usageError
+	switch {
+	case err == nil:
+	case err == flag.ErrHelp:
+	case errors.As(err, &uerr):
+		if !uerr.reported {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRunRejectsInvalidInput(t *testing.T) {
+	// Keep usage messages out of the test output
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() { os.Stderr = stderr }()
+
+	for _, args := range [][]string{
+		{},
+		{"semaphore"},
+		{"semaphore", "bogus"},
+		{"semaphore", "stress", "-impl", "9"},
+		{"semaphore", "stress", "-impl", "1,1"},
+		{"semaphore", "stress", "-releasers", "4", "-goroutines", "4"},
+		{"semaphore", "stress", "-format", "xml"},
+		{"semaphore", "fifo", "-waiters", "0"},
+		{"rwlock", "stress", "-policy", "random"},
+		{"rwlock", "stress", "-readers", "0", "-writers", "0"},
+		{"h2o", "daemon", "-atoms", "-1"},
+		{"h2o", "molecule", "-recipe", "H2O"},
+		{"h2o", "molecule", "-recipe", "H:0"},
+		{"queue", "blocking", "-duration", "0s"},
+		{"queue", "unbounded", "-burst", "0"},
+		{"fanout", "-workers", "many"},
+		{"counter", "extra"},
+	} {
+		err := run(args)
+		if !errors.As(err, new(usageError)) {
+			t.Errorf("run(%q) = %v, want a usage error", args, err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c, rest := lookup([]string{"queue", "context", "-duration", "1s"})
+	if c == nil || c.name != "queue context" || len(rest) != 2 {
+		t.Fatalf("lookup = %v, %q", c, rest)
+	}
+	if c, _ := lookup([]string{"queue"}); c != nil {
+		t.Fatalf("lookup of a bare group = %q, want nothing", c.name)
+	}
+}
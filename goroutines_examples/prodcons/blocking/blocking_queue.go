@@ -0,0 +1,60 @@
+// Package blocking sums numbers sent by producers over an unbuffered
+// channel, with consumers taking turns to add to a single sum.
+package blocking
+
+import (
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/queue"
+)
+
+// Default numbers of producers and consumers
+var (
+	NumProducer = 10
+	NumConsumer = 5
+)
+
+func producer(q *queue.Queue[int]) {
+	for q.Put(1) == nil { // keeps sending 1 to q until q is closed
+	}
+}
+
+func consumer(q *queue.Queue[int], sumCh chan int, finished chan<- struct{}) {
+	for {
+		num, err := q.Get() // keeps receiving data from q
+		if err != nil {     // q is closed and drained, should exit
+			finished <- struct{}{}
+			return
+		}
+		sumCh <- num + <-sumCh // sequentially increments sum
+	}
+}
+
+// Run lets producers and consumers run for d, and returns the sum
+func Run(producers int, consumers int, d time.Duration) int {
+	q := queue.New[int](0)
+	sumCh, finished := make(chan int, 1), make(chan struct{})
+
+	for i := 0; i < producers; i++ {
+		go producer(q)
+	}
+	for j := 0; j < consumers; j++ {
+		go consumer(q, sumCh, finished)
+	}
+
+	sumCh <- 0    // sends initial sum to unblock all consumers and producers
+	time.Sleep(d) // runs for d
+	q.Close()     // signals to all goroutines they should exit
+
+	// Once the program is done with producing and consuming the data, main will close q.
+	// Closing q frees up any producer blocked at putting to it, and every later Put fails,
+	// so all producers exit.
+
+	// Consumers are not stopped at once, though: they keep getting the items left in q,
+	// and only once q is drained does Get tell them q is closed.
+	// That way, no number a producer managed to put is lost from the sum.
+	for j := 0; j < consumers; j++ {
+		<-finished
+	}
+	return <-sumCh
+}
@@ -0,0 +1,74 @@
+// Package nonblocking sums numbers that producers and consumers pass
+// through a queue with non-blocking enqueues and dequeues.
+package nonblocking
+
+import (
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/queue"
+)
+
+func producer(done chan struct{}, q *queue.Queue[int]) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if ok := q.TryPut(1); !ok {
+			// do something
+		} else {
+			// do something
+		}
+	}
+}
+
+func consumer(done chan struct{}, q *queue.Queue[int], sumCh chan int) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		num, ok := q.TryGet()
+		if ok {
+			sumCh <- num + <-sumCh
+		}
+	}
+}
+
+// Default numbers of producers and consumers
+var (
+	NumProducer = 5
+	NumConsumer = 10
+)
+
+// Run lets producers and consumers run for d, and returns the sum
+func Run(producers int, consumers int, d time.Duration) int {
+	start, done := make(chan struct{}), make(chan struct{})
+	q, sumCh := queue.New[int](10), make(chan int, 1)
+	sumCh <- 0
+
+	for i := 0; i < producers; i++ {
+		go func() {
+			<-start
+			producer(done, q)
+		}()
+	}
+	for j := 0; j < consumers; j++ {
+		go func() {
+			<-start
+			go consumer(done, q, sumCh)
+		}()
+	}
+
+	close(start)
+	time.Sleep(d)
+	close(done)
+
+	sum := <-sumCh
+	sumCh <- 0 // unblock any consumer
+	return sum
+}
@@ -0,0 +1,76 @@
+// Package parallel sums numbers sent by producers to consumers that keep
+// local sums, so that adding consumers actually adds parallelism.
+package parallel
+
+import (
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/queue"
+)
+
+// In `blocking_queue.go` and `non_blocking_queue.go`
+// From the way consumer works, we can see that the consumers’ use of sumCh is sequential.
+// This means that adding more consumers will not speed up the process.
+
+func producer(q *queue.Queue[int]) {
+	for q.Put(1) == nil { // keeps sending 1 to q until q is closed
+	}
+}
+
+func consumer(q *queue.Queue[int], sumCh chan<- int) {
+	// Instead of storing one sum used by consumers sequentially, we can store many instances of sum on all consumers.
+	// local sum
+	sum := 0
+	for {
+		num, err := q.Get()
+		if err != nil { // q is closed and drained
+			// At the end of the process, we can send the sum back to main for consumption
+			sumCh <- sum
+			close(sumCh)
+			return
+		}
+		sum += num
+	}
+}
+
+// Default numbers of producers and consumers
+var (
+	NumProducer = 5
+	NumConsumer = 5
+)
+
+// Run lets producers and consumers run for d, and returns the sum
+func Run(producers int, consumers int, d time.Duration) int {
+	start := make(chan struct{})
+	q := queue.New[int](0)
+	sumChs := make([]chan int, 0, consumers)
+	for i := 0; i < consumers; i++ {
+		sumCh := make(chan int, 1)
+		sumChs = append(sumChs, sumCh)
+	}
+
+	for i := 0; i < producers; i++ {
+		go func() {
+			<-start
+			producer(q)
+		}()
+	}
+	for j := 0; j < consumers; j++ {
+		j := j // capture j in the scope
+		go func() {
+			<-start
+			consumer(q, sumChs[j])
+		}()
+	}
+
+	close(start)  // signal to all goroutines to start
+	time.Sleep(d) // run for d
+	q.Close()     // signal to all goroutines they should exit
+
+	// collect all sums
+	sum := 0
+	for _, ch := range sumChs {
+		sum += <-ch
+	}
+	return sum
+}
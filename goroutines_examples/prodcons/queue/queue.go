@@ -0,0 +1,170 @@
+// Package queue provides the queues used by the producer/consumer
+// examples: Queue, a bounded FIFO queue built on a buffered channel, and
+// Unbounded, a channel whose buffer grows so that bursty producers never
+// block on consumers that lag behind.
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Puts to a closed queue, and by Gets from a
+// closed queue once it is drained
+var ErrClosed = errors.New("queue: closed")
+
+// ErrTimeout is returned by PutTimeout and GetTimeout when they give up
+var ErrTimeout = errors.New("queue: timed out")
+
+// Queue is a FIFO queue of up to Cap items of type T.
+//
+// Closing a queue stops Puts, but not Gets: consumers drain the items
+// left in the queue before they are told it is closed, so that no item
+// that was put is lost.
+type Queue[T any] struct {
+	items chan T
+
+	mu      sync.Mutex
+	closing bool
+	closed  chan struct{}  // Closed once Close is called, to stop Puts
+	puts    sync.WaitGroup // Puts in progress, which Close waits for
+}
+
+// New returns a queue of up to capacity items. A queue of capacity 0
+// hands each item from a Put directly to a Get.
+func New[T any](capacity int) *Queue[T] {
+	return &Queue[T]{items: make(chan T, capacity), closed: make(chan struct{})}
+}
+
+// Put blocks until there is room for v in the queue, or the queue is closed
+func (q *Queue[T]) Put(v T) error {
+	return q.PutContext(context.Background(), v)
+}
+
+// TryPut puts v in the queue if there is room, without blocking
+func (q *Queue[T]) TryPut(v T) bool {
+	if !q.startPut() {
+		return false
+	}
+	defer q.puts.Done()
+	select {
+	case q.items <- v:
+		return true
+	// The default case in select provides a nice exit for the goroutine when all the cases are blocked.
+	// It makes the channel access asynchronous.
+	default:
+		return false
+	}
+}
+
+// PutContext is Put, but gives up when ctx is done
+func (q *Queue[T]) PutContext(ctx context.Context, v T) error {
+	if !q.startPut() {
+		return ErrClosed
+	}
+	defer q.puts.Done()
+	select {
+	case q.items <- v:
+		return nil
+	case <-q.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PutTimeout is Put, but gives up with ErrTimeout after d
+func (q *Queue[T]) PutTimeout(v T, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return timedOut(q.PutContext(ctx, v))
+}
+
+// startPut registers a Put with Close, reporting false if the queue is
+// already closed
+func (q *Queue[T]) startPut() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		return false
+	}
+	q.puts.Add(1)
+	return true
+}
+
+// Get blocks until there is an item in the queue and returns it, or
+// returns ErrClosed once the queue is closed and drained
+func (q *Queue[T]) Get() (T, error) {
+	return q.GetContext(context.Background())
+}
+
+// TryGet takes an item from the queue if there is one, without blocking
+func (q *Queue[T]) TryGet() (T, bool) {
+	select {
+	case v, ok := <-q.items:
+		return v, ok
+	default:
+		var zero T
+		return zero, false
+	}
+}
+
+// GetContext is Get, but gives up when ctx is done
+func (q *Queue[T]) GetContext(ctx context.Context) (T, error) {
+	select {
+	case v, ok := <-q.items:
+		if !ok {
+			return v, ErrClosed
+		}
+		return v, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// GetTimeout is Get, but gives up with ErrTimeout after d
+func (q *Queue[T]) GetTimeout(d time.Duration) (T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	v, err := q.GetContext(ctx)
+	return v, timedOut(err)
+}
+
+// timedOut turns the error of a context that timed out into ErrTimeout
+func timedOut(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
+// Close stops Puts, turning away blocked and later ones with ErrClosed.
+// Gets go on until the items left are drained, then return ErrClosed.
+// Closing a queue twice is harmless.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	if q.closing {
+		q.mu.Unlock()
+		return
+	}
+	q.closing = true
+	close(q.closed)
+	q.mu.Unlock()
+
+	// Once no Put can send anymore, closing items lets Gets drain it
+	q.puts.Wait()
+	close(q.items)
+}
+
+// Len is the number of items in the queue
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+// Cap is the number of items the queue can hold
+func (q *Queue[T]) Cap() int {
+	return cap(q.items)
+}
@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFIFO(t *testing.T) {
+	q := New[string](3)
+	for _, s := range []string{"a", "b", "c"} {
+		if err := q.Put(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Len() != 3 || q.Cap() != 3 {
+		t.Fatalf("Len %d, Cap %d, want 3 and 3", q.Len(), q.Cap())
+	}
+	if q.TryPut("d") {
+		t.Fatal("TryPut succeeded on a full queue")
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if got, err := q.Get(); got != want || err != nil {
+			t.Fatalf("Get = %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, ok := q.TryGet(); ok {
+		t.Fatal("TryGet succeeded on an empty queue")
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	q := New[int](1)
+	if _, err := q.GetTimeout(10 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("GetTimeout on an empty queue = %v, want %v", err, ErrTimeout)
+	}
+	q.Put(1)
+	if err := q.PutTimeout(2, 10*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("PutTimeout on a full queue = %v, want %v", err, ErrTimeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := q.PutContext(ctx, 2); !errors.Is(err, context.Canceled) {
+		t.Fatalf("PutContext = %v, want %v", err, context.Canceled)
+	}
+	if v, err := q.GetTimeout(time.Second); v != 1 || err != nil {
+		t.Fatalf("GetTimeout = %d, %v, want 1", v, err)
+	}
+}
+
+func TestCloseDrains(t *testing.T) {
+	q := New[int](2)
+	q.Put(1)
+	q.Put(2)
+	blocked := make(chan error)
+	go func() { blocked <- q.Put(3) }()
+	time.Sleep(10 * time.Millisecond)
+
+	q.Close()
+	q.Close() // Closing twice is harmless
+	if err := <-blocked; !errors.Is(err, ErrClosed) {
+		t.Fatalf("blocked Put = %v, want %v", err, ErrClosed)
+	}
+	if err := q.Put(4); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Put after Close = %v, want %v", err, ErrClosed)
+	}
+	if q.TryPut(4) {
+		t.Fatal("TryPut succeeded after Close")
+	}
+
+	// The items put before Close are still there
+	for _, want := range []int{1, 2} {
+		if got, err := q.Get(); got != want || err != nil {
+			t.Fatalf("Get = %d, %v, want %d", got, err, want)
+		}
+	}
+	if _, err := q.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get from a drained queue = %v, want %v", err, ErrClosed)
+	}
+	if _, ok := q.TryGet(); ok {
+		t.Fatal("TryGet succeeded on a drained queue")
+	}
+}
+
+// No item put is lost when the queue is closed while producers and
+// consumers are busy
+func TestCloseLosesNothing(t *testing.T) {
+	for _, capacity := range []int{0, 1, 10} {
+		q := New[int](capacity)
+		var put, got int
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for q.Put(1) == nil {
+					mu.Lock()
+					put++
+					mu.Unlock()
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for {
+					v, err := q.Get()
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					got += v
+					mu.Unlock()
+				}
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		q.Close()
+		wg.Wait()
+		if put != got {
+			t.Fatalf("capacity %d: %d items put, %d got", capacity, put, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package queue
+
+import "sync/atomic"
+
+// Unbounded is a channel that never blocks senders. Items sent on In wait
+// in a buffer that grows as needed until they are received from Out, in
+// the order they were sent.
+//
+// A goroutine moves items from In to the buffer and from the buffer to
+// Out. Closing In stops it once the buffer is drained, after which Out is
+// closed, so In must be closed for the goroutine to exit.
+type Unbounded[T any] struct {
+	in  chan T
+	out chan T
+	len atomic.Int64 // Items in the buffer
+}
+
+// UnboundedOption configures an Unbounded channel
+type UnboundedOption func(*unboundedOptions)
+
+type unboundedOptions struct {
+	highWatermark int
+	alert         func(n int)
+}
+
+// WithHighWatermark calls alert with the number of buffered items when
+// it goes above n. The buffer still grows past n; the alert is only a
+// warning that consumers are falling behind. alert is called again once
+// the buffer has gone back down to n/2 and then above n again.
+//
+// alert runs on the goroutine of the channel, so it must not block.
+func WithHighWatermark(n int, alert func(n int)) UnboundedOption {
+	return func(o *unboundedOptions) {
+		o.highWatermark = n
+		o.alert = alert
+	}
+}
+
+// NewUnbounded returns an empty unbounded channel and starts its goroutine,
+// which runs until In is closed and the items left are received
+func NewUnbounded[T any](opts ...UnboundedOption) *Unbounded[T] {
+	var o unboundedOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	u := &Unbounded[T]{in: make(chan T), out: make(chan T)}
+	go u.run(o)
+	return u
+}
+
+// In is the channel to send items on. Sends never block for long.
+func (u *Unbounded[T]) In() chan<- T {
+	return u.in
+}
+
+// Out is the channel to receive items from
+func (u *Unbounded[T]) Out() <-chan T {
+	return u.out
+}
+
+// Len is the number of items waiting in the buffer. An item is counted
+// shortly after its send returns, once it is in the buffer.
+func (u *Unbounded[T]) Len() int {
+	return int(u.len.Load())
+}
+
+func (u *Unbounded[T]) run(o unboundedOptions) {
+	var buf ring[T]
+	in := u.in
+	alerted := false
+	for in != nil || buf.len() > 0 {
+		// Only offer an item on out if there is one
+		var out chan T
+		var next T
+		if buf.len() > 0 {
+			out, next = u.out, buf.peek()
+		}
+
+		select {
+		case v, ok := <-in:
+			if !ok {
+				in = nil // Drain the buffer, then stop
+				continue
+			}
+			buf.push(v)
+			u.len.Store(int64(buf.len()))
+			if o.alert != nil && !alerted && buf.len() > o.highWatermark {
+				alerted = true
+				o.alert(buf.len())
+			}
+
+		case out <- next:
+			buf.pop()
+			u.len.Store(int64(buf.len()))
+			if alerted && buf.len() <= o.highWatermark/2 {
+				alerted = false
+			}
+		}
+	}
+	close(u.out)
+}
+
+// Smallest size of the buffer of a ring, which it never shrinks below
+const minRing = 16
+
+// ring is a FIFO buffer that doubles in size when full, and halves when
+// down to a quarter full
+type ring[T any] struct {
+	buf  []T
+	head int // Index of the first item
+	n    int // Number of items
+}
+
+func (r *ring[T]) len() int {
+	return r.n
+}
+
+func (r *ring[T]) push(v T) {
+	if r.n == len(r.buf) {
+		r.resize(max(minRing, 2*len(r.buf)))
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = v
+	r.n++
+}
+
+func (r *ring[T]) peek() T {
+	return r.buf[r.head]
+}
+
+func (r *ring[T]) pop() T {
+	v := r.buf[r.head]
+	var zero T
+	r.buf[r.head] = zero // Don't keep the item alive
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	if len(r.buf) > minRing && r.n <= len(r.buf)/4 {
+		r.resize(len(r.buf) / 2)
+	}
+	return v
+}
+
+// resize moves the items to a buffer of size
+func (r *ring[T]) resize(size int) {
+	buf := make([]T, size)
+	if r.n > 0 {
+		if end := r.head + r.n; end <= len(r.buf) {
+			copy(buf, r.buf[r.head:end])
+		} else {
+			copied := copy(buf, r.buf[r.head:])
+			copy(buf[copied:], r.buf[:end-len(r.buf)])
+		}
+	}
+	r.buf, r.head = buf, 0
+}
@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnboundedNeverBlocksSenders(t *testing.T) {
+	u := NewUnbounded[int]()
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			u.In() <- i // Nobody receives yet
+		}
+		close(u.In())
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("senders blocked with no receiver")
+	}
+	// The last item may not be counted yet when its send returns
+	deadline := time.Now().Add(time.Second)
+	for u.Len() != 1000 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Len = %d, want 1000", u.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Items come out in order, then Out is closed
+	want := 0
+	for v := range u.Out() {
+		if v != want {
+			t.Fatalf("received %d, want %d", v, want)
+		}
+		want++
+	}
+	if want != 1000 || u.Len() != 0 {
+		t.Fatalf("received %d items, %d left, want 1000 and 0", want, u.Len())
+	}
+}
+
+func TestUnboundedHighWatermark(t *testing.T) {
+	alerts := make(chan int, 10)
+	u := NewUnbounded[int](WithHighWatermark(10, func(n int) { alerts <- n }))
+	defer close(u.In())
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			u.In() <- i
+		}
+	}
+	receive := func(n int) {
+		for i := 0; i < n; i++ {
+			<-u.Out()
+		}
+	}
+	expect := func(want int) {
+		t.Helper()
+		select {
+		case n := <-alerts:
+			if want == 0 {
+				t.Fatalf("alerted with %d items", n)
+			}
+			if n != want {
+				t.Fatalf("alerted with %d items, want %d", n, want)
+			}
+		case <-time.After(20 * time.Millisecond):
+			if want != 0 {
+				t.Fatalf("no alert at %d items", want)
+			}
+		}
+	}
+
+	send(10)
+	expect(0) // At the watermark, not above it
+	send(5)
+	expect(11) // Once when going above it
+	receive(8) // Down to 7, not low enough to re-arm
+	send(10)
+	expect(0)
+	receive(12) // Down to 5, which re-arms the alert
+	send(6)
+	expect(11)
+}
+
+func TestRingGrowsAndShrinks(t *testing.T) {
+	var r ring[int]
+	for i := 0; i < 100; i++ {
+		r.push(i)
+	}
+	if len(r.buf) != 128 {
+		t.Fatalf("buffer of %d for 100 items, want 128", len(r.buf))
+	}
+	// Wrap around the end of the buffer
+	for i := 0; i < 50; i++ {
+		if v := r.pop(); v != i {
+			t.Fatalf("popped %d, want %d", v, i)
+		}
+		r.push(100 + i)
+	}
+	for i := 50; i < 150; i++ {
+		if v := r.pop(); v != i {
+			t.Fatalf("popped %d, want %d", v, i)
+		}
+	}
+	if r.len() != 0 || len(r.buf) != minRing {
+		t.Fatalf("%d items in a buffer of %d, want 0 in %d", r.len(), len(r.buf), minRing)
+	}
+}
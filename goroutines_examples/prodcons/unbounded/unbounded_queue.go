@@ -0,0 +1,87 @@
+// Package unbounded sums numbers that bursty producers send through an
+// unbounded channel, which buffers the bursts instead of blocking the
+// producers while consumers catch up.
+package unbounded
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/queue"
+)
+
+// Default numbers of producers and consumers
+var (
+	NumProducer = 10
+	NumConsumer = 5
+)
+
+// Options configure the producers and the queue of Run
+type Options struct {
+	// Producers send Burst numbers at once, then pause for Pause
+	Burst int
+	Pause time.Duration
+	// Number of buffered items above which Run warns that consumers are
+	// falling behind
+	HighWatermark int
+}
+
+// DefaultOptions returns the Options the example runs with by default
+func DefaultOptions() Options {
+	return Options{Burst: 1000, Pause: 10 * time.Millisecond, HighWatermark: 5000}
+}
+
+func producer(done chan struct{}, in chan<- int, opts Options) {
+	for {
+		for i := 0; i < opts.Burst; i++ {
+			in <- 1 // never blocks for long, however far behind consumers are
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(opts.Pause):
+		}
+	}
+}
+
+func consumer(out <-chan int, sumCh chan int, finished chan<- struct{}) {
+	for num := range out { // out is closed once in is closed and drained
+		sumCh <- num + <-sumCh // sequentially increments sum
+	}
+	finished <- struct{}{}
+}
+
+// Run lets producers and consumers run for d, and returns the sum
+func Run(producers int, consumers int, d time.Duration, opts Options) int {
+	u := queue.NewUnbounded[int](queue.WithHighWatermark(opts.HighWatermark, func(n int) {
+		fmt.Printf("%d items buffered, consumers are falling behind\n", n)
+	}))
+	done := make(chan struct{})
+	sumCh, finished := make(chan int, 1), make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			producer(done, u.In(), opts)
+		}()
+	}
+	for j := 0; j < consumers; j++ {
+		go consumer(u.Out(), sumCh, finished)
+	}
+
+	sumCh <- 0    // sends initial sum to unblock all consumers
+	time.Sleep(d) // runs for d
+	close(done)   // signals to all producers they should exit
+
+	// In can only be closed once no producer sends on it anymore. Consumers
+	// then drain the numbers still buffered, so none is lost from the sum.
+	wg.Wait()
+	close(u.In())
+	for j := 0; j < consumers; j++ {
+		<-finished
+	}
+	return <-sumCh
+}
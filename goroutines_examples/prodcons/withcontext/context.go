@@ -0,0 +1,76 @@
+// Package withcontext sums numbers sent by producers to consumers that
+// keep local sums, and stops them all by cancelling a context.
+package withcontext
+
+import (
+	"context"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/prodcons/queue"
+)
+
+// Due to the ubiquity of the use of done channels, Go 1.7 introduces the context package that does the same thing and more.
+// When we write a goroutine that spawns a number of goroutines that might each acquire some resources
+// (e.g. memory, file descriptors, database connection) and will exit during the program lifetime,
+// we want to release the resources held as soon as the former exits.
+
+func producer(ctx context.Context, q *queue.Queue[int]) {
+	for q.PutContext(ctx, 1) == nil { // keeps sending 1 to q until ctx is cancelled
+	}
+}
+
+func consumer(ctx context.Context, q *queue.Queue[int], sumCh chan<- int) {
+	sum := 0
+	for {
+		num, err := q.GetContext(ctx)
+		if err != nil { // ctx is cancelled
+			sumCh <- sum
+			close(sumCh)
+			return
+		}
+		sum += num
+	}
+}
+
+// Default numbers of producers and consumers
+var (
+	NumProducer = 5
+	NumConsumer = 5
+)
+
+// Run lets producers and consumers run for d, and returns the sum
+func Run(producers int, consumers int, d time.Duration) int {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	start := make(chan struct{})
+	q := queue.New[int](10)
+	sumChs := make([]chan int, 0, consumers)
+	for i := 0; i < consumers; i++ {
+		sumCh := make(chan int, 1)
+		sumChs = append(sumChs, sumCh)
+	}
+
+	for i := 0; i < producers; i++ {
+		go func() {
+			<-start
+			producer(ctx, q)
+		}()
+	}
+	for j := 0; j < consumers; j++ {
+		j := j
+		go func() {
+			<-start
+			consumer(ctx, q, sumChs[j])
+		}()
+	}
+
+	close(start)
+	time.Sleep(d)
+	cancel() // cancel the context of this run
+
+	sum := 0
+	for _, ch := range sumChs {
+		sum += <-ch
+	}
+	return sum
+}
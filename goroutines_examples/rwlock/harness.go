@@ -0,0 +1,113 @@
+package rwlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// StressConfig describes a stress run
+type StressConfig struct {
+	Readers  int           // Goroutines that keep taking the lock to read
+	Writers  int           // Goroutines that keep taking the lock to write
+	Duration time.Duration // How long the goroutines keep running
+	Hold     time.Duration // How long the lock is held each time
+}
+
+// StressResult is what each goroutine of a stress run did.
+// Goroutine i is a reader if i < Readers, and a writer otherwise.
+type StressResult struct {
+	Config  StressConfig
+	Elapsed time.Duration
+	Ops     []int                 // Times each goroutine took the lock
+	Waits   []semaphore.Histogram // Time each goroutine waited for the lock
+	// Longest wait of each goroutine, including a wait still
+	// unfinished at the end of the run
+	MaxWait []time.Duration
+}
+
+// Stress runs cfg.Readers readers and cfg.Writers writers that keep taking
+// and releasing l for cfg.Duration
+func Stress(l *RWLock, cfg StressConfig) *StressResult {
+	n := cfg.Readers + cfg.Writers
+	result := &StressResult{
+		Config:  cfg,
+		Ops:     make([]int, n),
+		Waits:   make([]semaphore.Histogram, n),
+		MaxWait: make([]time.Duration, n),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		lock, unlock := l.RLockContext, l.RUnlock
+		if i >= cfg.Readers {
+			lock, unlock = l.LockContext, l.Unlock
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				begin := time.Now()
+				err := lock(ctx)
+				wait := time.Since(begin)
+				result.MaxWait[i] = max(result.MaxWait[i], wait)
+				if err != nil {
+					break
+				}
+				result.Waits[i].Record(wait)
+				result.Ops[i]++
+				if cfg.Hold > 0 {
+					time.Sleep(cfg.Hold)
+				}
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+// Starvation compares how readers and writers fared in a stress run
+type Starvation struct {
+	ReaderOps int
+	WriterOps int
+	// Readers and writers that never got the lock
+	StarvedReaders int
+	StarvedWriters int
+	// Worst case and 99th percentile of the time waited for the lock
+	ReaderMaxWait time.Duration
+	WriterMaxWait time.Duration
+	ReaderP99     time.Duration
+	WriterP99     time.Duration
+}
+
+func (r *StressResult) Starvation() Starvation {
+	var s Starvation
+	var readers, writers semaphore.Histogram
+	for i, ops := range r.Ops {
+		if i < r.Config.Readers {
+			s.ReaderOps += ops
+			if ops == 0 {
+				s.StarvedReaders++
+			}
+			s.ReaderMaxWait = max(s.ReaderMaxWait, r.MaxWait[i])
+			readers.Merge(&r.Waits[i])
+		} else {
+			s.WriterOps += ops
+			if ops == 0 {
+				s.StarvedWriters++
+			}
+			s.WriterMaxWait = max(s.WriterMaxWait, r.MaxWait[i])
+			writers.Merge(&r.Waits[i])
+		}
+	}
+	s.ReaderP99 = readers.Quantile(0.99)
+	s.WriterP99 = writers.Quantile(0.99)
+	return s
+}
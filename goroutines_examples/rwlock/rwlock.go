@@ -0,0 +1,189 @@
+// Package rwlock builds readers-writer locks out of the semaphores in the
+// semaphore package, following the three classic solutions to the
+// readers-writers problem.
+//
+// Binary semaphores stand in for mutexes throughout. Unlike a mutex, a
+// semaphore may be released by a goroutine other than the one that took
+// it, which the writer-preferring lock relies on.
+package rwlock
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// Policy chooses who goes first when readers and writers both wait
+type Policy int
+
+const (
+	// ReaderPreferring lets readers in while any reader holds the lock,
+	// so a steady stream of readers starves writers
+	ReaderPreferring Policy = iota
+	// WriterPreferring keeps new readers out while any writer waits,
+	// so a steady stream of writers starves readers
+	WriterPreferring
+	// Fair serves readers and writers in the order they arrive,
+	// letting consecutive readers share the lock
+	Fair
+)
+
+var policyNames = []string{"reader", "writer", "fair"}
+
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// ParsePolicy returns the policy called name, as printed by String
+func ParsePolicy(name string) (Policy, error) {
+	for i, n := range policyNames {
+		if n == name {
+			return Policy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("rwlock: unknown policy %q", name)
+}
+
+// Policies lists every policy
+var Policies = []Policy{ReaderPreferring, WriterPreferring, Fair}
+
+// RWLock is a readers-writer lock. Any number of readers, or a single
+// writer, may hold it at once.
+type RWLock struct {
+	policy Policy
+
+	readers  int                          // Readers holding or about to hold the lock
+	writers  int                          // Writers holding or waiting, for WriterPreferring
+	countMu  semaphore.SemaphoreInterface // Guards readers
+	writerMu semaphore.SemaphoreInterface // Guards writers
+	resource semaphore.SemaphoreInterface // Held by the writer, or by readers as a group
+	gate     semaphore.SemaphoreInterface // readTry for WriterPreferring, service queue for Fair
+}
+
+// binary is a FIFO semaphore with a single permit, which panics when
+// released twice
+func binary() semaphore.SemaphoreInterface {
+	return semaphore.NewSemaphore4(1, 1, semaphore.WithStrict())
+}
+
+func New(policy Policy) *RWLock {
+	if policy < 0 || int(policy) >= len(policyNames) {
+		panic(fmt.Sprintf("rwlock: unknown policy %d", policy))
+	}
+	return &RWLock{
+		policy:   policy,
+		countMu:  binary(),
+		writerMu: binary(),
+		resource: binary(),
+		gate:     binary(),
+	}
+}
+
+func (l *RWLock) Policy() Policy {
+	return l.policy
+}
+
+func (l *RWLock) RLock() {
+	l.RLockContext(context.Background())
+}
+
+// RLockContext is RLock, but gives up when ctx is done.
+// A reader that gives up does not hold the lock.
+func (l *RWLock) RLockContext(ctx context.Context) error {
+	// WriterPreferring: readers must get past the gate, which writers close
+	// while any of them waits. Fair: everyone queues at the gate in turn.
+	if l.policy != ReaderPreferring {
+		if err := l.gate.AcquireContext(ctx); err != nil {
+			return err
+		}
+		defer l.gate.Release()
+	}
+
+	// Readers behind a first reader waiting for the resource wait here
+	if err := l.countMu.AcquireContext(ctx); err != nil {
+		return err
+	}
+	defer l.countMu.Release()
+	l.readers++
+	if l.readers == 1 {
+		// The first reader in takes the resource for every reader
+		if err := l.resource.AcquireContext(ctx); err != nil {
+			l.readers--
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *RWLock) RUnlock() {
+	l.countMu.Acquire()
+	defer l.countMu.Release()
+	if l.readers == 0 {
+		panic("rwlock: RUnlock of unlocked RWLock")
+	}
+	l.readers--
+	if l.readers == 0 {
+		// The last reader out gives the resource back
+		l.resource.Release()
+	}
+}
+
+func (l *RWLock) Lock() {
+	l.LockContext(context.Background())
+}
+
+// LockContext is Lock, but gives up when ctx is done.
+// A writer that gives up does not hold the lock.
+func (l *RWLock) LockContext(ctx context.Context) error {
+	switch l.policy {
+	case WriterPreferring:
+		// The first writer to wait closes the gate to new readers,
+		// and the last writer to leave opens it again
+		if err := l.writerMu.AcquireContext(ctx); err != nil {
+			return err
+		}
+		l.writers++
+		if l.writers == 1 {
+			if err := l.gate.AcquireContext(ctx); err != nil {
+				l.writers--
+				l.writerMu.Release()
+				return err
+			}
+		}
+		l.writerMu.Release()
+		if err := l.resource.AcquireContext(ctx); err != nil {
+			l.leaveWriters()
+			return err
+		}
+		return nil
+
+	case Fair:
+		// Wait for our turn, then for the readers ahead of us to leave
+		if err := l.gate.AcquireContext(ctx); err != nil {
+			return err
+		}
+		defer l.gate.Release()
+	}
+	return l.resource.AcquireContext(ctx)
+}
+
+func (l *RWLock) Unlock() {
+	l.resource.Release()
+	if l.policy == WriterPreferring {
+		l.leaveWriters()
+	}
+}
+
+// leaveWriters removes a writer, opening the gate if it was the last one
+func (l *RWLock) leaveWriters() {
+	l.writerMu.Acquire()
+	defer l.writerMu.Release()
+	l.writers--
+	if l.writers == 0 {
+		l.gate.Release()
+	}
+}
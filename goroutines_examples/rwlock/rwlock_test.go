@@ -0,0 +1,225 @@
+package rwlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const blockTimeout = 50 * time.Millisecond
+
+// locked runs lock in the background and reports whether it returned
+// within d. A blocked lock keeps running and returns later.
+func locked(lock func(), d time.Duration) (bool, <-chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		lock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true, done
+	case <-time.After(d):
+		return false, done
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s was not unblocked", what)
+	}
+}
+
+func TestExclusion(t *testing.T) {
+	for _, p := range Policies {
+		t.Run(p.String(), func(t *testing.T) {
+			l := New(p)
+			var readers, writers atomic.Int32
+			var bad atomic.Bool
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 200; j++ {
+						if i%4 == 0 {
+							l.Lock()
+							if writers.Add(1) != 1 || readers.Load() != 0 {
+								bad.Store(true)
+							}
+							writers.Add(-1)
+							l.Unlock()
+						} else {
+							l.RLock()
+							readers.Add(1)
+							if writers.Load() != 0 {
+								bad.Store(true)
+							}
+							readers.Add(-1)
+							l.RUnlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if bad.Load() {
+				t.Fatal("a writer held the lock alongside another holder")
+			}
+		})
+	}
+}
+
+func TestReadersShare(t *testing.T) {
+	for _, p := range Policies {
+		t.Run(p.String(), func(t *testing.T) {
+			l := New(p)
+			l.RLock()
+			if ok, _ := locked(l.RLock, time.Second); !ok {
+				t.Fatal("second reader blocked")
+			}
+			if ok, done := locked(l.Lock, blockTimeout); ok {
+				t.Fatal("writer got in alongside readers")
+			} else {
+				l.RUnlock()
+				l.RUnlock()
+				waitDone(t, done, "writer")
+			}
+		})
+	}
+}
+
+// While a reader holds the lock and a writer waits, a new reader gets
+// in only if readers are preferred
+func TestReaderBehindWaitingWriter(t *testing.T) {
+	for _, p := range Policies {
+		t.Run(p.String(), func(t *testing.T) {
+			l := New(p)
+			l.RLock()
+			if ok, _ := locked(l.Lock, blockTimeout); ok {
+				t.Fatal("writer got in alongside a reader")
+			}
+			ok, done := locked(l.RLock, blockTimeout)
+			if ok != (p == ReaderPreferring) {
+				t.Fatalf("new reader got in = %v behind a waiting writer", ok)
+			}
+			l.RUnlock()
+			if ok {
+				l.RUnlock()
+				return
+			}
+			// The writer goes first, then the reader
+			time.Sleep(blockTimeout)
+			select {
+			case <-done:
+				t.Fatal("reader overtook the waiting writer")
+			default:
+			}
+			l.Unlock()
+			waitDone(t, done, "reader")
+		})
+	}
+}
+
+// While a writer holds the lock, a reader and then a second writer queue up.
+// Fair serves the reader first, WriterPreferring the second writer.
+func TestQueuedOrder(t *testing.T) {
+	for _, p := range []Policy{WriterPreferring, Fair} {
+		t.Run(p.String(), func(t *testing.T) {
+			l := New(p)
+			l.Lock()
+			var order []string
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			record := func(who string) {
+				mu.Lock()
+				order = append(order, who)
+				mu.Unlock()
+			}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				l.RLock()
+				record("reader")
+				l.RUnlock()
+			}()
+			time.Sleep(blockTimeout)
+			go func() {
+				defer wg.Done()
+				l.Lock()
+				record("writer")
+				time.Sleep(blockTimeout)
+				l.Unlock()
+			}()
+			time.Sleep(blockTimeout)
+			l.Unlock()
+			wg.Wait()
+
+			first := "reader"
+			if p == WriterPreferring {
+				first = "writer"
+			}
+			if order[0] != first {
+				t.Fatalf("served %v, want the %s first", order, first)
+			}
+		})
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	for _, p := range Policies {
+		t.Run(p.String(), func(t *testing.T) {
+			l := New(p)
+			l.Lock()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if err := l.RLockContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("RLockContext = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if err := l.LockContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("LockContext = %v, want %v", err, context.DeadlineExceeded)
+			}
+			l.Unlock()
+
+			// Goroutines that gave up must leave the lock usable
+			if ok, _ := locked(l.RLock, time.Second); !ok {
+				t.Fatal("RLock blocked after waiters gave up")
+			}
+			l.RUnlock()
+			if ok, _ := locked(l.Lock, time.Second); !ok {
+				t.Fatal("Lock blocked after waiters gave up")
+			}
+		})
+	}
+}
+
+func TestStress(t *testing.T) {
+	for _, p := range Policies {
+		t.Run(p.String(), func(t *testing.T) {
+			r := Stress(New(p), StressConfig{Readers: 4, Writers: 2, Duration: 100 * time.Millisecond})
+			s := r.Starvation()
+			if s.ReaderOps+s.WriterOps == 0 {
+				t.Fatal("nobody got the lock")
+			}
+			if s.ReaderMaxWait > r.Elapsed || s.WriterMaxWait > r.Elapsed {
+				t.Fatalf("waits %v and %v longer than the %v run", s.ReaderMaxWait, s.WriterMaxWait, r.Elapsed)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, p := range Policies {
+		if got, err := ParsePolicy(p.String()); err != nil || got != p {
+			t.Errorf("ParsePolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := ParsePolicy("random"); err == nil {
+		t.Error("ParsePolicy accepted an unknown policy")
+	}
+}
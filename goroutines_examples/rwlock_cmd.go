@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/rwlock"
+)
+
+// selectPolicies parses a comma-separated list of policies, or "all"
+func selectPolicies(list string) ([]rwlock.Policy, error) {
+	if list == "all" {
+		return rwlock.Policies, nil
+	}
+	var policies []rwlock.Policy
+	for _, name := range strings.Split(list, ",") {
+		p, err := rwlock.ParsePolicy(strings.TrimSpace(name))
+		if err != nil {
+			return nil, usagef("unknown -policy %q, want all or a list of reader, writer, fair", name)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func runRWLockStress(fs *flag.FlagSet, args []string) error {
+	policyList := fs.String("policy", "all", "policies to run: all, or a comma-separated list of reader, writer, fair")
+	readers := fs.Int("readers", 8, "number of reader goroutines")
+	writers := fs.Int("writers", 2, "number of writer goroutines")
+	duration := fs.Duration("duration", time.Second, "how long to run each policy")
+	hold := fs.Duration("hold", 100*time.Microsecond, "how long the lock is held each time")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	policies, err := selectPolicies(*policyList)
+	if err != nil {
+		return err
+	}
+	if *readers < 0 || *writers < 0 || *readers+*writers == 0 {
+		return usagef("-readers and -writers must not be negative, and not both zero")
+	}
+	if err := positiveDuration("duration", *duration); err != nil {
+		return err
+	}
+	if *hold < 0 {
+		return usagef("-hold must not be negative, got %v", *hold)
+	}
+
+	cfg := rwlock.StressConfig{Readers: *readers, Writers: *writers, Duration: *duration, Hold: *hold}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "policy\treader ops\twriter ops\tstarved r\tstarved w\treader p99\twriter p99\treader max\twriter max\t")
+	for _, p := range policies {
+		s := rwlock.Stress(rwlock.New(p), cfg).Starvation()
+		fmt.Fprintf(w, "%v\t%d\t%d\t%d\t%d\t%v\t%v\t%v\t%v\t\n", p,
+			s.ReaderOps, s.WriterOps, s.StarvedReaders, s.StarvedWriters,
+			s.ReaderP99, s.WriterP99,
+			s.ReaderMaxWait.Round(time.Microsecond), s.WriterMaxWait.Round(time.Microsecond))
+	}
+	return w.Flush()
+}
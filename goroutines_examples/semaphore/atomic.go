@@ -0,0 +1,128 @@
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Semaphore design with an atomic fast path
+// While nobody is waiting, Acquire and Release only touch an atomic
+// counter. Goroutines that find no permits park on a FIFO list of
+// channels, and a Release that sees parked waiters hands its permit
+// straight to the oldest one under the mutex.
+//
+// A waiter announces itself in waiting before it looks at count, and a
+// Release adds to count before it looks at waiting, so at least one of
+// them sees the other and a permit is never left behind while a waiter
+// parks. Acquire only takes the fast path while nobody is parked, so it
+// cannot overtake a queued waiter.
+type Semaphore4 struct {
+	count   atomic.Int64 // Available permits
+	waiting atomic.Int64 // Waiters queued or about to queue
+
+	mu      sync.Mutex
+	waiters list.List // Channels of parked waiters, oldest first
+
+	capacity int64
+	opts     options
+}
+
+func NewSemaphore4(capacity int, initial_count int, opts ...Option) *Semaphore4 {
+	checkCapacity(capacity, initial_count)
+	s := &Semaphore4{
+		capacity: int64(capacity),
+		opts:     newOptions(opts),
+	}
+	s.count.Store(int64(initial_count))
+	s.waiters.Init()
+	return s
+}
+
+// take decrements count if it is positive
+func (s *Semaphore4) take() bool {
+	for {
+		n := s.count.Load()
+		if n == 0 {
+			return false
+		}
+		if s.count.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+func (s *Semaphore4) Acquire() {
+	s.AcquireContext(context.Background())
+}
+
+func (s *Semaphore4) TryAcquire() bool {
+	return s.waiting.Load() == 0 && s.take()
+}
+
+// AcquireContext is Acquire, but gives up when ctx is done.
+// A waiter that gives up is removed from the list, and a permit that
+// was handed to it while it was giving up is released again.
+func (s *Semaphore4) AcquireContext(ctx context.Context) error {
+	// Fast path
+	if s.waiting.Load() == 0 && s.take() {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.waiting.Add(1)
+	if s.waiters.Len() == 0 && s.take() {
+		s.waiting.Add(-1)
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{}, 1)
+	ele := s.waiters.PushBack(ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	granted := false
+	select {
+	case <-ch: // Handed a permit before we could leave
+		granted = true
+	default:
+		s.waiters.Remove(ele)
+		s.waiting.Add(-1)
+	}
+	s.mu.Unlock()
+	if granted {
+		s.Release()
+	}
+	return ctx.Err()
+}
+
+func (s *Semaphore4) Release() error {
+	for {
+		n := s.count.Load()
+		if n >= s.capacity {
+			return s.opts.overRelease()
+		}
+		if s.count.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+
+	// Slow path, hand permits to parked waiters
+	if s.waiting.Load() > 0 {
+		s.mu.Lock()
+		for s.waiters.Len() > 0 && s.take() {
+			ch := s.waiters.Remove(s.waiters.Front()).(chan struct{})
+			s.waiting.Add(-1)
+			ch <- struct{}{}
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
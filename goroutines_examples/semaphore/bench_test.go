@@ -0,0 +1,243 @@
+package semaphore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// condSemaphore is the textbook semaphore built on sync.Mutex and sync.Cond
+type condSemaphore struct {
+	mu    sync.Mutex
+	cond  sync.Cond
+	count int
+}
+
+func newCondSemaphore(initial_count int) *condSemaphore {
+	s := &condSemaphore{count: initial_count}
+	s.cond.L = &s.mu
+	return s
+}
+
+func (s *condSemaphore) Acquire() {
+	s.mu.Lock()
+	for s.count == 0 {
+		s.cond.Wait()
+	}
+	s.count--
+	s.mu.Unlock()
+}
+
+func (s *condSemaphore) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return false
+	}
+	s.count--
+	return true
+}
+
+func (s *condSemaphore) AcquireContext(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.count == 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.cond.Wait()
+	}
+	s.count--
+	return nil
+}
+
+func (s *condSemaphore) Release() error {
+	s.mu.Lock()
+	s.count++
+	s.mu.Unlock()
+	s.cond.Signal()
+	return nil
+}
+
+// spinSemaphore only uses an atomic counter, and yields while it is zero
+type spinSemaphore struct {
+	count atomic.Int64
+}
+
+func newSpinSemaphore(initial_count int) *spinSemaphore {
+	s := new(spinSemaphore)
+	s.count.Store(int64(initial_count))
+	return s
+}
+
+func (s *spinSemaphore) Acquire() {
+	for !s.TryAcquire() {
+		runtime.Gosched()
+	}
+}
+
+func (s *spinSemaphore) TryAcquire() bool {
+	for {
+		n := s.count.Load()
+		if n == 0 {
+			return false
+		}
+		if s.count.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+func (s *spinSemaphore) AcquireContext(ctx context.Context) error {
+	for !s.TryAcquire() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		runtime.Gosched()
+	}
+	return nil
+}
+
+func (s *spinSemaphore) Release() error {
+	s.count.Add(1)
+	return nil
+}
+
+// Designs under benchmark, followed by the baselines
+var benchmarked = append(implementations[:len(implementations):len(implementations)],
+	struct {
+		name string
+		new  func(initial_count int) SemaphoreInterface
+	}{"Cond", func(n int) SemaphoreInterface { return newCondSemaphore(n) }},
+	struct {
+		name string
+		new  func(initial_count int) SemaphoreInterface
+	}{"Spin", func(n int) SemaphoreInterface { return newSpinSemaphore(n) }},
+)
+
+var (
+	benchGoroutines = []int{1, 4, 16, 64}
+	benchPermits    = []int{1, 4}
+)
+
+// GOMAXPROCS values to benchmark, without repeats on small machines
+var benchProcs = func() []int {
+	procs := []int{1, 2, 4, runtime.NumCPU()}
+	slices.Sort(procs)
+	return slices.Compact(procs)
+}()
+
+// withProcs runs f with GOMAXPROCS set to procs
+func withProcs(b *testing.B, procs int, f func(b *testing.B)) {
+	b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+		f(b)
+	})
+}
+
+func closeSemaphore(s SemaphoreInterface) {
+	if c, ok := s.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// spread runs b.N calls of op across goroutines
+func spread(b *testing.B, goroutines int, op func()) {
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		n := b.N / goroutines
+		if g < b.N%goroutines {
+			n++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				op()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Each goroutine repeatedly takes a permit and gives it back, as a lock would
+func BenchmarkAcquireRelease(b *testing.B) {
+	for _, impl := range benchmarked {
+		b.Run(impl.name, func(b *testing.B) {
+			for _, procs := range benchProcs {
+				withProcs(b, procs, func(b *testing.B) {
+					for _, permits := range benchPermits {
+						for _, goroutines := range benchGoroutines {
+							b.Run(fmt.Sprintf("permits=%d/goroutines=%d", permits, goroutines), func(b *testing.B) {
+								s := impl.new(permits)
+								defer closeSemaphore(s)
+								b.ResetTimer()
+								spread(b, goroutines, func() {
+									s.Acquire()
+									s.Release()
+								})
+							})
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+// Releasers hand permits to acquirers, as in the stress test
+func BenchmarkHandoff(b *testing.B) {
+	for _, impl := range benchmarked {
+		b.Run(impl.name, func(b *testing.B) {
+			for _, procs := range benchProcs {
+				withProcs(b, procs, func(b *testing.B) {
+					for _, goroutines := range benchGoroutines {
+						b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+							s := impl.new(0)
+							defer closeSemaphore(s)
+							b.ResetTimer()
+							done := make(chan struct{})
+							go func() {
+								spread(b, goroutines, func() {
+									// A bounded semaphore refuses permits while full
+									for s.Release() != nil {
+										runtime.Gosched()
+									}
+								})
+								close(done)
+							}()
+							spread(b, goroutines, s.Acquire)
+							<-done
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// TryAcquire on an empty semaphore, the cost of being turned away
+func BenchmarkTryAcquireEmpty(b *testing.B) {
+	for _, impl := range benchmarked {
+		b.Run(impl.name, func(b *testing.B) {
+			s := impl.new(0)
+			defer closeSemaphore(s)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.TryAcquire()
+			}
+		})
+	}
+}
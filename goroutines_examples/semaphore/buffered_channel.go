@@ -0,0 +1,72 @@
+package semaphore
+
+import "context"
+
+// Simplest semaphore design using a buffered channel
+// Initially, the buffered channel is empty, so send will not block
+// However, each send fills up the buffered channel until it's full,
+// so further sends will block
+//
+// This is a correct implementation of a semaphore.
+// While it is FIFO in practice due to an implementation detail
+// in the Go runtime, its FIFO behaviour is not actually guaranteed
+// by the Go specification
+//
+// The channel holds at most capacity slots, so a Release with every
+// slot already empty is an over-release and fails instead of blocking.
+type Semaphore1 struct {
+	sem  chan struct{}
+	opts options
+}
+
+func NewSemaphore1(capacity int, initial_count int, opts ...Option) *Semaphore1 {
+	checkCapacity(capacity, initial_count)
+	sem := Semaphore1{
+		sem:  make(chan struct{}, capacity),
+		opts: newOptions(opts),
+	}
+	for ; initial_count < capacity; initial_count++ {
+		sem.Acquire()
+	}
+	return &sem
+}
+
+func (s *Semaphore1) Acquire() {
+	// Send to the channel to decrement the number of empty slots
+	// Blocks if there are no slots remaining
+	s.sem <- struct{}{}
+	// Blocked goroutines will be unblocked in FIFO order as of Go 1.17
+}
+
+// TryAcquire takes an empty slot only if one is available right now
+func (s *Semaphore1) TryAcquire() bool {
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// AcquireContext is Acquire, but gives up when ctx is done.
+// A send that did not happen cannot have taken a slot,
+// so giving up never consumes a permit.
+func (s *Semaphore1) AcquireContext(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Semaphore1) Release() error {
+	// Receive from the channel to increment the number of empty slots
+	select {
+	case <-s.sem:
+		return nil
+	default:
+		// Every slot is already empty
+		return s.opts.overRelease()
+	}
+}
@@ -0,0 +1,309 @@
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/internal/cleanup"
+)
+
+// Helper struct that extends list.List with a helper method
+type chanQueue struct{ list.List }
+
+func newChanQueue() *chanQueue {
+	q := new(chanQueue)
+	q.Init()
+	return q
+}
+
+func (q *chanQueue) Peek() request {
+	return q.Front().Value.(request)
+}
+
+func (q *chanQueue) Pop() request {
+	ele := q.Front()
+	q.Remove(ele)
+	return ele.Value.(request)
+}
+
+// Delete removes the request holding ch from the queue, reporting whether it was found
+func (q *chanQueue) Delete(ch chan struct{}) bool {
+	for ele := q.Front(); ele != nil; ele = ele.Next() {
+		if ele.Value.(request).ch == ch {
+			q.Remove(ele)
+			return true
+		}
+	}
+	return false
+}
+
+// Kinds of requests a waiter can make to the daemon
+const (
+	acquireReq = iota
+	tryAcquireReq
+	cancelReq
+	releaseReq
+)
+
+// A request carries the number of permits wanted or released, and the
+// channel of the waiter that made it. Releases go through the same channel
+// as acquires, so the daemon sees every request in the order it was made:
+// a TryAcquire made after a Release returned sees the released permits.
+type request struct {
+	kind int
+	n    int
+	ch   chan struct{}
+}
+
+// Centralised semaphore design using a daemon goroutine
+// Each Acquire and Release communicates with the daemon,
+// which keeps waiters blocked if necessary,
+// and chooses which waiters to unblock based on a FIFO queue
+//
+// A waiter may ask for several permits at once. The queue is strictly FIFO:
+// while the oldest waiter cannot be satisfied, nobody behind it is either,
+// so a large request is never starved by a stream of small ones.
+//
+// Waiters hand the daemon a channel with a buffer of one. The daemon
+// sends a permit on it to unblock the waiter, and closes it to turn
+// the waiter away, so the daemon itself never blocks on a waiter.
+//
+// The daemon runs until Close is called or the context it was created
+// with is done. It then turns every queued waiter away, and closes done
+// so that later calls fail fast with ErrClosed instead of blocking.
+type Semaphore2 struct {
+	requestCh chan request
+
+	cancel context.CancelFunc
+	closed atomic.Bool
+	done   chan struct{} // Closed once the daemon has exited
+}
+
+func NewSemaphore2(initial_count int) *Semaphore2 {
+	return NewSemaphore2WithContext(context.Background(), initial_count)
+}
+
+// NewSemaphore2WithContext is NewSemaphore2, with a daemon that also
+// shuts down when ctx is done
+func NewSemaphore2WithContext(ctx context.Context, initial_count int) *Semaphore2 {
+	sem := new(Semaphore2)
+	sem.requestCh = make(chan request, 100)
+	sem.done = make(chan struct{})
+	ctx, sem.cancel = context.WithCancel(ctx)
+
+	// The daemon must not reference sem, so that sem can be garbage
+	// collected once users drop it, which stops the daemon
+	requestCh, done := sem.requestCh, sem.done
+	go func() {
+		count := initial_count
+		// The FIFO queue that stores the requests of blocked waiters
+		waiters := newChanQueue()
+
+		// Unblock waiters from the front of the queue while the oldest fits
+		wake := func() {
+			for waiters.Len() > 0 && waiters.Peek().n <= count {
+				req := waiters.Pop()
+				count -= req.n
+				req.ch <- struct{}{}
+			}
+		}
+
+		for {
+			select {
+			case req := <-requestCh:
+				switch req.kind {
+				case releaseReq: // Increment and unblock waiters
+					count += req.n
+					wake()
+
+				case acquireReq: // Decrement or add a waiter
+					if waiters.Len() == 0 && req.n <= count {
+						count -= req.n
+						req.ch <- struct{}{} // Don't keep waiter blocked
+					} else {
+						waiters.PushBack(req) // Add waiter to back of queue
+					}
+
+				case tryAcquireReq: // Decrement or turn the caller away
+					if waiters.Len() == 0 && req.n <= count {
+						count -= req.n
+						req.ch <- struct{}{}
+					}
+					close(req.ch)
+
+				case cancelReq: // Waiter gave up
+					// If the waiter is still queued, it never received a permit.
+					// Otherwise the permit we sent is still buffered in ch, and
+					// the waiter will find it before the close and hand it back.
+					waiters.Delete(req.ch)
+					close(req.ch)
+					// The waiter may have been holding up the ones behind it
+					wake()
+				}
+
+			case <-ctx.Done(): // Shut down
+				for waiters.Len() > 0 {
+					close(waiters.Pop().ch) // Turn the waiter away
+				}
+				close(done)
+				return
+			}
+		}
+	}()
+
+	cleanup.StopWhenUnreachable(sem, sem.cancel)
+	return sem
+}
+
+// Technically, it is possible that an acquire is blocked on the first send to s.requestCh,
+// even before it’s able to send its channel to the daemon. If we assume that channels do not unblock in FIFO order,
+// it’s possible that it remains blocked on this first send forever while other goroutines are constantly sending new acquire requests to the daemon.
+
+// So the answer is no, it’s not actually FIFO in the sense that a goroutine A that calls Acquire
+// can be blocked before another goroutine B, and yet goroutine B unblocks before goroutine A.
+
+// However, the ordering is enforced from the moment that the first send actually succeeds.
+// Since the daemon is capable of emptying its request queues relatively quickly, and the request
+// queues can be buffered to a length where it does not block in practice, it is possible to make an
+// argument that this semaphore is FIFO under certain conditions.
+//
+// Acquire panics with ErrClosed if the semaphore is shut down
+// before a permit is taken.
+func (s *Semaphore2) Acquire() {
+	s.AcquireN(1)
+}
+
+// AcquireN blocks until n permits can be taken at once
+func (s *Semaphore2) AcquireN(n int) {
+	checkWeight(n)
+	defer runtime.KeepAlive(s) // Don't stop the daemon while we wait
+	ch := make(chan struct{}, 1)
+	// Send daemon a channel that can be used to unblock us
+	select {
+	case s.requestCh <- request{acquireReq, n, ch}:
+	case <-s.done:
+		panic(ErrClosed)
+	}
+	// Block until daemon decides to unblock us
+	if !reply(ch, s.done) {
+		panic(ErrClosed)
+	}
+}
+
+// TryAcquire takes a permit only if one is available and nobody is queued
+func (s *Semaphore2) TryAcquire() bool {
+	return s.TryAcquireN(1)
+}
+
+// TryAcquireN takes n permits only if they are available and nobody is queued
+func (s *Semaphore2) TryAcquireN(n int) bool {
+	checkWeight(n)
+	defer runtime.KeepAlive(s)
+	ch := make(chan struct{}, 1)
+	select {
+	case s.requestCh <- request{tryAcquireReq, n, ch}:
+	case <-s.done:
+		return false
+	}
+	return reply(ch, s.done)
+}
+
+// AcquireContext is Acquire, but gives up when ctx is done.
+// A waiter that gives up is removed from the daemon's queue, and a permit
+// that was sent to it while it was giving up is released again.
+func (s *Semaphore2) AcquireContext(ctx context.Context) error {
+	return s.AcquireNContext(ctx, 1)
+}
+
+// AcquireNContext is AcquireN, but gives up when ctx is done
+func (s *Semaphore2) AcquireNContext(ctx context.Context, n int) error {
+	checkWeight(n)
+	defer runtime.KeepAlive(s)
+	ch := make(chan struct{}, 1)
+	select {
+	case s.requestCh <- request{acquireReq, n, ch}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrClosed
+	}
+
+	select {
+	case _, ok := <-ch:
+		if !ok {
+			return ErrClosed
+		}
+		return nil
+	case <-s.done:
+		if !reply(ch, s.done) {
+			return ErrClosed
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	select {
+	case s.requestCh <- request{cancelReq, n, ch}:
+	case <-s.done:
+	}
+	if reply(ch, s.done) {
+		s.ReleaseN(n)
+	}
+	return ctx.Err()
+}
+
+// reply waits for a daemon to answer on ch, reporting whether it sent a permit.
+// Once the daemon has exited and closed done, ch holds whatever the daemon
+// sent before exiting.
+func reply(ch chan struct{}, done chan struct{}) bool {
+	select {
+	case _, ok := <-ch:
+		return ok
+	case <-done:
+		select {
+		case _, ok := <-ch:
+			return ok
+		default:
+			return false
+		}
+	}
+}
+
+func (s *Semaphore2) Release() error {
+	return s.ReleaseN(1)
+}
+
+// ReleaseN returns n permits at once
+func (s *Semaphore2) ReleaseN(n int) error {
+	checkWeight(n)
+	select {
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case s.requestCh <- request{releaseReq, n, nil}:
+		return nil
+	case <-s.done:
+		return ErrClosed
+	}
+}
+
+// Close stops the daemon and waits for it to exit.
+// Waiters still queued are woken up with ErrClosed.
+func (s *Semaphore2) Close() error {
+	if s.closed.Swap(true) {
+		return ErrClosed
+	}
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func checkWeight(n int) {
+	if n < 0 {
+		panic("semaphore: negative number of permits")
+	}
+}
@@ -0,0 +1,241 @@
+package semaphore
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// acquiredN is acquired for AcquireN
+func acquiredN(s *Semaphore2, n int, d time.Duration) (bool, <-chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		s.AcquireN(n)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true, done
+	case <-time.After(d):
+		return false, done
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s was not unblocked", what)
+	}
+}
+
+func TestSemaphore2Weighted(t *testing.T) {
+	s := NewSemaphore2(5)
+	if ok, _ := acquiredN(s, 3, time.Second); !ok {
+		t.Fatal("AcquireN(3) blocked with 5 permits available")
+	}
+	if s.TryAcquireN(3) {
+		t.Fatal("TryAcquireN(3) succeeded with 2 permits available")
+	}
+	if !s.TryAcquireN(2) {
+		t.Fatal("TryAcquireN(2) failed with 2 permits available")
+	}
+	s.ReleaseN(4)
+	if !s.TryAcquireN(4) {
+		t.Fatal("TryAcquireN(4) failed after ReleaseN(4)")
+	}
+}
+
+func TestSemaphore2TryAcquireSeesRelease(t *testing.T) {
+	s := NewSemaphore2(0)
+	defer s.Close()
+	for i := 0; i < 1000; i++ {
+		s.Release()
+		if !s.TryAcquire() {
+			t.Fatalf("round %d: TryAcquire failed right after Release returned", i)
+		}
+	}
+}
+
+func TestSemaphore2WeightedFIFO(t *testing.T) {
+	s := NewSemaphore2(0)
+	ok, big := acquiredN(s, 5, 20*time.Millisecond)
+	if ok {
+		t.Fatal("AcquireN(5) did not block with no permits available")
+	}
+	ok, small := acquiredN(s, 1, 20*time.Millisecond)
+	if ok {
+		t.Fatal("AcquireN(1) did not block behind a queued waiter")
+	}
+
+	// One permit would fit the small waiter, but the big one is older
+	s.ReleaseN(1)
+	select {
+	case <-small:
+		t.Fatal("small waiter overtook the big waiter at the head of the queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if s.TryAcquire() {
+		t.Fatal("TryAcquire overtook queued waiters")
+	}
+
+	s.ReleaseN(4)
+	waitDone(t, big, "big waiter")
+	s.Release()
+	waitDone(t, small, "small waiter")
+}
+
+func TestSemaphore2WeightedCancelHead(t *testing.T) {
+	s := NewSemaphore2(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- s.AcquireNContext(ctx, 5) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ok, small := acquiredN(s, 1, 20*time.Millisecond)
+	if ok {
+		t.Fatal("AcquireN(1) did not block behind a queued waiter")
+	}
+
+	// Once the head gives up, the permits it was waiting for go to the next waiter
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("AcquireNContext = %v, want %v", err, context.Canceled)
+	}
+	waitDone(t, small, "waiter behind the cancelled head")
+	if !s.TryAcquire() {
+		t.Fatal("permits held for the cancelled head were lost")
+	}
+}
+
+func TestSemaphore2CloseWakesWaiters(t *testing.T) {
+	s := NewSemaphore2(0)
+	errCh := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() { errCh <- s.AcquireNContext(context.Background(), 2) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errCh:
+			if err != ErrClosed {
+				t.Fatalf("queued waiter got %v, want %v", err, ErrClosed)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close did not wake queued waiters")
+		}
+	}
+	if err := s.Close(); err != ErrClosed {
+		t.Fatalf("second Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestSemaphore2FailsFastAfterClose(t *testing.T) {
+	s := NewSemaphore2(1)
+	s.Close()
+
+	if err := s.Release(); err != ErrClosed {
+		t.Fatalf("Release = %v, want %v", err, ErrClosed)
+	}
+	if s.TryAcquire() {
+		t.Fatal("TryAcquire succeeded on a closed semaphore")
+	}
+	if err := s.AcquireContext(context.Background()); err != ErrClosed {
+		t.Fatalf("AcquireContext = %v, want %v", err, ErrClosed)
+	}
+	defer func() {
+		if r := recover(); r != ErrClosed {
+			t.Fatalf("Acquire panicked with %v, want %v", r, ErrClosed)
+		}
+	}()
+	s.Acquire()
+}
+
+func TestSemaphore2ContextShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewSemaphore2WithContext(ctx, 0)
+	errCh := make(chan error)
+	go func() { errCh <- s.AcquireContext(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != ErrClosed {
+			t.Fatalf("queued waiter got %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the context did not shut the daemon down")
+	}
+}
+
+func TestSemaphore2CloseReclaimsDaemon(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	sems := make([]*Semaphore2, 50)
+	for i := range sems {
+		sems[i] = NewSemaphore2(1)
+	}
+	for _, s := range sems {
+		s.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Close, want %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSemaphore2DroppedStopsDaemon(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		s := NewSemaphore2(1)
+		s.Acquire()
+		s.Release()
+	}
+	// Also a semaphore dropped while a permit is still held
+	NewSemaphore2(1).Acquire()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after dropping the semaphores, want %d", runtime.NumGoroutine(), baseline)
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// A waiter keeps the semaphore alive, even once its user has dropped it
+func TestSemaphore2WaiterKeepsDaemon(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	panicked := make(chan any, 1)
+	go func(s *Semaphore2) {
+		defer func() { panicked <- recover() }()
+		s.AcquireN(1)
+	}(NewSemaphore2WithContext(ctx, 0))
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case v := <-panicked:
+		t.Fatalf("waiter returned with %v while still waiting", v)
+	default:
+	}
+	// Shutting the daemon down is the only way to end the wait now
+	cancel()
+	if v := <-panicked; v != ErrClosed {
+		t.Fatalf("AcquireN panicked with %v, want %v", v, ErrClosed)
+	}
+}
@@ -0,0 +1,110 @@
+package semaphore
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FIFOWaiter identifies a goroutine started by VerifyFIFO
+type FIFOWaiter struct {
+	ID        int   // Position in which the waiter was started
+	Goroutine int64 // Runtime goroutine ID
+}
+
+func (w FIFOWaiter) String() string {
+	return fmt.Sprintf("waiter %d (goroutine %d)", w.ID, w.Goroutine)
+}
+
+// FIFOResult is the order in which waiters enqueued and were granted permits
+type FIFOResult struct {
+	Enqueued []FIFOWaiter
+	Granted  []FIFOWaiter
+}
+
+// FIFOViolation reports the first permit granted out of order
+type FIFOViolation struct {
+	Position int        // Index into Granted
+	Granted  FIFOWaiter // Waiter that received the permit
+	Expected FIFOWaiter // Older waiter that should have received it
+}
+
+func (v *FIFOViolation) Error() string {
+	return fmt.Sprintf("permit %d went to %v ahead of %v", v.Position, v.Granted, v.Expected)
+}
+
+// VerifyFIFO checks that sem unblocks waiters in the order they enqueued.
+// sem must start with no permits available.
+//
+// Waiters are started one at a time, and each is given settle to block in
+// Acquire before the next one starts. Permits are then released one at a
+// time, waiting for each to be granted before releasing the next, so the
+// recorded grant order is exactly the order in which sem chose waiters.
+//
+// The returned error is a *FIFOViolation if the orders differ.
+func VerifyFIFO(sem SemaphoreInterface, num_waiters int, settle time.Duration) (FIFOResult, error) {
+	var (
+		mu     sync.Mutex
+		result FIFOResult
+	)
+	grantedCh := make(chan struct{}, num_waiters)
+
+	for i := 0; i < num_waiters; i++ {
+		enqueued := make(chan struct{})
+		go func() {
+			w := FIFOWaiter{i, goroutineID()}
+			mu.Lock()
+			result.Enqueued = append(result.Enqueued, w)
+			mu.Unlock()
+			close(enqueued)
+
+			sem.Acquire()
+
+			mu.Lock()
+			result.Granted = append(result.Granted, w)
+			mu.Unlock()
+			grantedCh <- struct{}{}
+		}()
+		<-enqueued
+		time.Sleep(settle)
+	}
+
+	mu.Lock()
+	early := len(result.Granted)
+	mu.Unlock()
+	if early > 0 {
+		return result, fmt.Errorf("%d waiters acquired before any Release", early)
+	}
+
+	for i := 0; i < num_waiters; i++ {
+		sem.Release()
+		select {
+		case <-grantedCh:
+		case <-time.After(time.Second + settle):
+			return result, fmt.Errorf("release %d did not unblock any waiter", i)
+		}
+	}
+
+	for i, w := range result.Granted {
+		if w != result.Enqueued[i] {
+			return result, &FIFOViolation{i, w, result.Enqueued[i]}
+		}
+	}
+	return result, nil
+}
+
+// goroutineID parses the ID of the calling goroutine from its stack trace
+func goroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// The trace starts with "goroutine <id> [running]:"
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseInt(string(buf), 10, 64)
+	return id
+}
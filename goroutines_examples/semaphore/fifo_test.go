@@ -0,0 +1,87 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifyFIFO(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			result, err := VerifyFIFO(impl.new(0), 20, 5*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Granted) != 20 {
+				t.Fatalf("%d waiters granted, want 20", len(result.Granted))
+			}
+		})
+	}
+}
+
+// lifoSemaphore unblocks the newest waiter first
+type lifoSemaphore struct {
+	mu      sync.Mutex
+	count   int
+	waiters []chan struct{}
+}
+
+func (s *lifoSemaphore) Acquire() {
+	s.AcquireContext(context.Background())
+}
+
+func (s *lifoSemaphore) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count > 0 {
+		s.count--
+		return true
+	}
+	return false
+}
+
+func (s *lifoSemaphore) AcquireContext(ctx context.Context) error {
+	s.mu.Lock()
+	if s.count > 0 {
+		s.count--
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.waiters = append(s.waiters, ch)
+	s.mu.Unlock()
+	<-ch
+	return nil
+}
+
+func (s *lifoSemaphore) Release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.waiters); n > 0 {
+		close(s.waiters[n-1])
+		s.waiters = s.waiters[:n-1]
+	} else {
+		s.count++
+	}
+	return nil
+}
+
+func TestVerifyFIFODetectsViolation(t *testing.T) {
+	result, err := VerifyFIFO(new(lifoSemaphore), 3, 5*time.Millisecond)
+	var v *FIFOViolation
+	if !errors.As(err, &v) {
+		t.Fatalf("VerifyFIFO = %v, want a FIFO violation", err)
+	}
+	if v.Position != 0 || v.Granted.ID != 2 || v.Expected.ID != 0 {
+		t.Fatalf("got %v, want waiter 2 ahead of waiter 0 at permit 0", v)
+	}
+	if v.Granted.Goroutine == 0 || v.Granted.Goroutine == v.Expected.Goroutine {
+		t.Fatalf("bad goroutine IDs in %v", v)
+	}
+	if len(result.Granted) != 3 {
+		t.Fatalf("%d waiters granted, want 3", len(result.Granted))
+	}
+}
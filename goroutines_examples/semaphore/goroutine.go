@@ -0,0 +1,137 @@
+package semaphore
+
+import "context"
+
+// A signal is sent once on each link of the wait chain, by the waiter that
+// owns the link, to the waiter right behind it.
+type signal struct {
+	isHead bool        // The receiver is now at the head of the chain
+	waitCh chan signal // Otherwise, the link the receiver must wait on instead
+}
+
+func getSignal(s signal) (bool, chan signal) {
+	return s.isHead, s.waitCh
+}
+
+// Semaphore design using a chain of linked channels
+// Each waiter waits for the one ahead of it, and only the head of the
+// chain takes permits from the permits channel, so waiters are served
+// in the order they joined the chain.
+//
+// Every channel is buffered: waitQueue holds the link at the tail of the
+// chain, each link holds the one signal its owner sends, and permits holds
+// up to capacity released permits. No send ever blocks, so neither Acquire
+// nor Release starts a goroutine, and a Release beyond capacity is refused.
+type Semaphore3 struct {
+	waitQueue chan chan signal
+	permits   chan struct{}
+	opts      options
+}
+
+func NewSemaphore3(capacity int, initial_count int, opts ...Option) *Semaphore3 {
+	checkCapacity(capacity, initial_count)
+	s := Semaphore3{
+		waitQueue: make(chan chan signal, 1),
+		permits:   make(chan struct{}, capacity),
+		opts:      newOptions(opts),
+	}
+
+	// the first waiter is at the head straight away
+	first := make(chan signal, 1)
+	first <- signal{true, nil}
+	s.waitQueue <- first
+
+	for i := 0; i < initial_count; i++ {
+		s.permits <- struct{}{}
+	}
+
+	return &s
+}
+
+// join adds a new link at the tail of the chain,
+// returning the link to signal the next waiter with
+func (s *Semaphore3) join() chan signal {
+	next := make(chan signal, 1)
+	s.waitQueue <- next
+	return next
+}
+
+// Acquire forms a link in the waitQueue, waits to reach the head,
+// then takes a permit and hands the head over to the next waiter.
+func (s *Semaphore3) Acquire() {
+	s.AcquireContext(context.Background())
+}
+
+// TryAcquire joins the chain like Acquire, but leaves instead of waiting.
+// It fails at once if anyone is still ahead of it, even with permits
+// available, since whoever is ahead takes them first.
+func (s *Semaphore3) TryAcquire() bool {
+	waitCh := <-s.waitQueue
+	next := s.join()
+
+	isHead := false
+	for !isHead {
+		select {
+		case sig := <-waitCh:
+			isHead, waitCh = getSignal(sig)
+		default:
+			// someone ahead is still waiting
+			next <- signal{false, waitCh}
+			return false
+		}
+	}
+
+	select {
+	case <-s.permits:
+		next <- signal{true, nil}
+		return true
+	default:
+		next <- signal{true, nil}
+		return false
+	}
+}
+
+// AcquireContext is Acquire, but gives up when ctx is done.
+// A waiter that gives up splices itself out of the chain by telling the
+// next waiter what it was waiting on, and takes no permit.
+func (s *Semaphore3) AcquireContext(ctx context.Context) error {
+	var waitCh chan signal
+	select {
+	case waitCh = <-s.waitQueue:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	next := s.join()
+
+	// wait until everyone ahead has taken a permit or left
+	isHead := false
+	for !isHead {
+		select {
+		case sig := <-waitCh:
+			isHead, waitCh = getSignal(sig)
+		case <-ctx.Done():
+			next <- signal{false, waitCh}
+			return ctx.Err()
+		}
+	}
+
+	// only the head reads from permits
+	select {
+	case <-s.permits:
+		next <- signal{true, nil}
+		return nil
+	case <-ctx.Done():
+		next <- signal{true, nil}
+		return ctx.Err()
+	}
+}
+
+// Release adds a permit for the head of the chain to take
+func (s *Semaphore3) Release() error {
+	select {
+	case s.permits <- struct{}{}:
+		return nil
+	default:
+		return s.opts.overRelease()
+	}
+}
@@ -0,0 +1,89 @@
+package semaphore
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSemaphore3NoGoroutineLeak(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	s := NewSemaphore3(1000, 0)
+
+	// A burst of releases with nobody waiting must not park anything
+	for i := 0; i < 1000; i++ {
+		if err := s.Release(); err != nil {
+			t.Fatalf("release %d: %v", i, err)
+		}
+	}
+	if n := runtime.NumGoroutine(); n != baseline {
+		t.Fatalf("%d goroutines after releasing, want %d", n, baseline)
+	}
+	for i := 0; i < 1000; i++ {
+		s.Acquire()
+	}
+
+	// Waiters that give up leave nothing behind either
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			s.AcquireContext(ctx)
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 100; i++ {
+		s.TryAcquire()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after the waiters left, want %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// and the chain still works after they left
+	s.Release()
+	if !s.TryAcquire() {
+		t.Fatal("TryAcquire failed with a permit available")
+	}
+}
+
+func TestSemaphore3SkipsCancelledWaiter(t *testing.T) {
+	s := NewSemaphore3(10, 0)
+
+	first := make(chan struct{})
+	go func() {
+		s.Acquire()
+		close(first)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	middle := make(chan error)
+	go func() { middle <- s.AcquireContext(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+
+	last := make(chan struct{})
+	go func() {
+		s.Acquire()
+		close(last)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-middle; err != context.Canceled {
+		t.Fatalf("cancelled waiter got %v, want %v", err, context.Canceled)
+	}
+	s.Release()
+	waitDone(t, first, "first waiter")
+	s.Release()
+	waitDone(t, last, "waiter behind the cancelled one")
+}
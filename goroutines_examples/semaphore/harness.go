@@ -0,0 +1,106 @@
+package semaphore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// StressConfig describes a stress run
+type StressConfig struct {
+	Releasers  int           // Goroutines that keep calling Release
+	Goroutines int           // Total goroutines, the rest keep calling Acquire
+	Duration   time.Duration // How long acquirers keep running
+	Log        io.Writer     // If set, every operation is logged here
+}
+
+// StressResult is what each goroutine of a stress run did.
+// Goroutine i is a releaser if i < Releasers, and an acquirer otherwise.
+type StressResult struct {
+	Config  StressConfig
+	Elapsed time.Duration // How long acquirers actually ran
+	Ops     []int         // Operations completed by each goroutine
+	Waits   []Histogram   // Time each acquirer spent in AcquireContext
+}
+
+// Stress runs cfg.Releasers goroutines that keep calling Release and
+// cfg.Goroutines-cfg.Releasers goroutines that keep calling AcquireContext
+// for cfg.Duration, then stops the acquirers followed by the releasers.
+func Stress(s SemaphoreInterface, cfg StressConfig) *StressResult {
+	releasersCtx, releasersCancel := context.WithCancel(context.Background())
+	acquirersCtx, acquirersCancel := context.WithCancel(context.Background())
+	var releasersWg, acquirersWg sync.WaitGroup
+
+	result := &StressResult{
+		Config: cfg,
+		Ops:    make([]int, cfg.Goroutines),
+		Waits:  make([]Histogram, cfg.Goroutines),
+	}
+	logf := func(format string, args ...any) {
+		if cfg.Log != nil {
+			fmt.Fprintf(cfg.Log, format, args...)
+		}
+	}
+
+	for i := 0; i < cfg.Releasers; i++ {
+		releasersWg.Add(1)
+		go func() {
+			defer releasersWg.Done()
+			ops := 0
+			for releasersCtx.Err() == nil {
+				logf("T%d: Release\n", i)
+				if s.Release() == nil {
+					ops++
+				}
+			}
+			result.Ops[i] = ops
+		}()
+	}
+
+	start := time.Now()
+	for i := cfg.Releasers; i < cfg.Goroutines; i++ {
+		acquirersWg.Add(1)
+		go func() {
+			defer acquirersWg.Done()
+			ops := 0
+			waits := &result.Waits[i]
+			for acquirersCtx.Err() == nil {
+				logf("T%d: Waiting\n", i)
+				begin := time.Now()
+				if s.AcquireContext(acquirersCtx) != nil {
+					break
+				}
+				waits.Record(time.Since(begin))
+				logf("T%d: Unblocked\n", i)
+				ops++
+			}
+			result.Ops[i] = ops
+		}()
+	}
+
+	time.Sleep(cfg.Duration)
+
+	acquirersCancel()
+	acquirersWg.Wait()
+	result.Elapsed = time.Since(start)
+	releasersCancel()
+	releasersWg.Wait()
+
+	return result
+}
+
+// StressTest runs num_releasers goroutines that keep calling Release and
+// num_goroutines-num_releasers goroutines that keep calling Acquire for one
+// second, logging to stdout, and returns the number of operations completed
+// by each goroutine.
+func StressTest(s SemaphoreInterface, num_releasers int, num_goroutines int) []int {
+	return Stress(s, StressConfig{
+		Releasers:  num_releasers,
+		Goroutines: num_goroutines,
+		Duration:   time.Second,
+		Log:        os.Stdout,
+	}).Ops
+}
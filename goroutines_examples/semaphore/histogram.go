@@ -0,0 +1,94 @@
+package semaphore
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Histogram counts durations in buckets whose upper bounds are powers of
+// two nanoseconds. Bucket i holds durations in (2^(i-1), 2^i] ns, and
+// bucket 0 holds everything up to 1ns.
+//
+// A Histogram is not safe for concurrent use; record into one histogram
+// per goroutine and Merge them afterwards.
+type Histogram struct {
+	Buckets [64]uint64
+	Count   uint64
+	Sum     time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+func bucketOf(d time.Duration) int {
+	if d <= 1 {
+		return 0
+	}
+	return bits.Len64(uint64(d - 1))
+}
+
+// BucketBound is the upper bound of bucket i
+func BucketBound(i int) time.Duration {
+	if i >= 63 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(1) << i
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.Buckets[bucketOf(d)]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Sum += d
+}
+
+func (h *Histogram) Merge(o *Histogram) {
+	if o.Count == 0 {
+		return
+	}
+	for i, n := range o.Buckets {
+		h.Buckets[i] += n
+	}
+	if h.Count == 0 || o.Min < h.Min {
+		h.Min = o.Min
+	}
+	if o.Max > h.Max {
+		h.Max = o.Max
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound on the q-th quantile, 0 <= q <= 1:
+// the bound of the bucket holding it, capped at the largest duration seen.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.Buckets {
+		seen += n
+		if seen >= rank {
+			return min(BucketBound(i), h.Max)
+		}
+	}
+	return h.Max
+}
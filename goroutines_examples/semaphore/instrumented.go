@@ -0,0 +1,219 @@
+package semaphore
+
+import (
+	"bufio"
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Instrumented wraps a semaphore and keeps track of who holds and who waits
+// for its permits, so that a stalled program can tell whether it is stuck
+// on the semaphore.
+type Instrumented struct {
+	sem   SemaphoreInterface
+	name  string
+	start time.Time // Waits start at an offset from start
+
+	mu       sync.Mutex
+	holders  int64
+	waiting  int64
+	acquires uint64
+	failures uint64
+	releases uint64
+	wait     time.Duration // Of the waits that are over
+	// Sum of the offsets at which the waits still going on started, so
+	// that they can be counted in Snapshot without tracking each one
+	waitStarts time.Duration
+	latency    Histogram
+}
+
+// Snapshot is the state of an Instrumented semaphore at one point in time
+type Snapshot struct {
+	Name     string `json:"name"`
+	Holders  int64  `json:"holders"`  // Permits taken minus permits given back
+	Waiting  int64  `json:"waiting"`  // Goroutines blocked in Acquire or AcquireContext
+	Acquires uint64 `json:"acquires"` // Permits taken
+	Failures uint64 `json:"failures"` // TryAcquire turned away, or AcquireContext given up
+	Releases uint64 `json:"releases"` // Successful Releases
+
+	// Time spent blocked in Acquire and AcquireContext, including by
+	// goroutines that gave up or are still waiting
+	TotalWait time.Duration `json:"total_wait_ns"`
+	// Time each successful Acquire and AcquireContext took
+	Latency Histogram `json:"latency"`
+}
+
+// NewInstrumented wraps sem. name identifies the semaphore in Snapshot,
+// expvar and Prometheus metric names.
+func NewInstrumented(name string, sem SemaphoreInterface) *Instrumented {
+	return &Instrumented{sem: sem, name: name, start: time.Now()}
+}
+
+func (s *Instrumented) Acquire() {
+	s.acquire(func() error {
+		s.sem.Acquire()
+		return nil
+	})
+}
+
+func (s *Instrumented) TryAcquire() bool {
+	ok := s.sem.TryAcquire()
+	s.mu.Lock()
+	if ok {
+		s.holders++
+		s.acquires++
+	} else {
+		s.failures++
+	}
+	s.mu.Unlock()
+	return ok
+}
+
+func (s *Instrumented) AcquireContext(ctx context.Context) error {
+	return s.acquire(func() error { return s.sem.AcquireContext(ctx) })
+}
+
+// acquire counts the caller as waiting while wait blocks
+func (s *Instrumented) acquire(wait func() error) error {
+	s.mu.Lock()
+	begin := time.Since(s.start)
+	s.waiting++
+	s.waitStarts += begin
+	s.mu.Unlock()
+
+	err := wait()
+
+	s.mu.Lock()
+	d := time.Since(s.start) - begin
+	s.waiting--
+	s.waitStarts -= begin
+	s.wait += d
+	if err == nil {
+		s.holders++
+		s.acquires++
+		s.latency.Record(d)
+	} else {
+		s.failures++
+	}
+	s.mu.Unlock()
+	return err
+}
+
+func (s *Instrumented) Release() error {
+	err := s.sem.Release()
+	if err == nil {
+		s.mu.Lock()
+		s.holders--
+		s.releases++
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Snapshot returns the current state of the semaphore
+func (s *Instrumented) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Each wait still going on has lasted from its start until now
+	ongoing := time.Duration(s.waiting)*time.Since(s.start) - s.waitStarts
+	return Snapshot{
+		Name:      s.name,
+		Holders:   s.holders,
+		Waiting:   s.waiting,
+		Acquires:  s.acquires,
+		Failures:  s.failures,
+		Releases:  s.releases,
+		TotalWait: s.wait + ongoing,
+		Latency:   s.latency,
+	}
+}
+
+// Serialises PublishExpvar, so that two semaphores of the same name
+// cannot both find it unused
+var publishMu sync.Mutex
+
+// PublishExpvar exports the snapshot of the semaphore as the expvar
+// variable name, served as JSON on /debug/vars. Unlike expvar.Publish, it
+// returns an error instead of panicking if the name is already in use.
+func (s *Instrumented) PublishExpvar() error {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+	if expvar.Get(s.name) != nil {
+		return fmt.Errorf("semaphore: expvar %q already published", s.name)
+	}
+	expvar.Publish(s.name, expvar.Func(func() any { return s.Snapshot() }))
+	return nil
+}
+
+// Buckets of the latency histogram exported to Prometheus, from about a
+// microsecond to about a minute. A fixed set keeps series stable across
+// scrapes, and the cumulative counts still include faster acquires.
+const (
+	firstPromBucket = 10
+	lastPromBucket  = 36
+)
+
+// WritePrometheus writes the snapshot in the Prometheus text format,
+// with metric names prefixed by the name of the semaphore
+func (s *Instrumented) WritePrometheus(w io.Writer) error {
+	snap := s.Snapshot()
+	p := metricName(snap.Name)
+	bw := bufio.NewWriter(w)
+
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(bw, "# HELP %s_%s %s\n# TYPE %s_%s %s\n%s_%s %v\n", p, name, help, p, name, kind, p, name, value)
+	}
+	metric("holders", "gauge", "Permits currently held.", snap.Holders)
+	metric("waiting", "gauge", "Goroutines blocked waiting for a permit.", snap.Waiting)
+	metric("acquires_total", "counter", "Permits taken.", snap.Acquires)
+	metric("acquire_failures_total", "counter", "Acquires that gave up or were turned away.", snap.Failures)
+	metric("releases_total", "counter", "Permits given back.", snap.Releases)
+	metric("wait_seconds_total", "counter", "Time spent waiting for permits.", snap.TotalWait.Seconds())
+
+	h := snap.Latency
+	fmt.Fprintf(bw, "# HELP %s_acquire_latency_seconds Time taken by successful acquires.\n", p)
+	fmt.Fprintf(bw, "# TYPE %s_acquire_latency_seconds histogram\n", p)
+	var cumulative uint64
+	for i := 0; i <= lastPromBucket; i++ {
+		cumulative += h.Buckets[i]
+		if i >= firstPromBucket {
+			fmt.Fprintf(bw, "%s_acquire_latency_seconds_bucket{le=\"%g\"} %d\n", p, BucketBound(i).Seconds(), cumulative)
+		}
+	}
+	fmt.Fprintf(bw, "%s_acquire_latency_seconds_bucket{le=\"+Inf\"} %d\n", p, h.Count)
+	fmt.Fprintf(bw, "%s_acquire_latency_seconds_sum %g\n", p, h.Sum.Seconds())
+	fmt.Fprintf(bw, "%s_acquire_latency_seconds_count %d\n", p, h.Count)
+	return bw.Flush()
+}
+
+// metricName turns name into a valid Prometheus metric name
+func metricName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// PrometheusHandler serves the metrics of sems in the Prometheus text format
+func PrometheusHandler(sems ...*Instrumented) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, s := range sems {
+			if err := s.WritePrometheus(w); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package semaphore
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstrumentedSnapshot(t *testing.T) {
+	s := NewInstrumented("db", NewSemaphore4(10, 1))
+	s.Acquire()
+	if s.TryAcquire() {
+		t.Fatal("TryAcquire succeeded with no permits available")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Acquire()
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for s.Snapshot().Waiting != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("blocked Acquire not counted as waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// A stalled waiter already counts in the total wait
+	if wait := s.Snapshot().TotalWait; wait < 10*time.Millisecond {
+		t.Fatalf("total wait %v while blocked for 10ms", wait)
+	}
+	s.Release()
+	waitDone(t, done, "waiter")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.AcquireContext(ctx)
+
+	snap := s.Snapshot()
+	want := Snapshot{Name: "db", Holders: 1, Waiting: 0, Acquires: 2, Failures: 2, Releases: 1}
+	if snap.Name != want.Name || snap.Holders != want.Holders || snap.Waiting != want.Waiting ||
+		snap.Acquires != want.Acquires || snap.Failures != want.Failures || snap.Releases != want.Releases {
+		t.Fatalf("got %+v, want %+v", snap, want)
+	}
+	if snap.Latency.Count != 2 || snap.Latency.Max < 10*time.Millisecond {
+		t.Fatalf("latency of 2 acquires, one blocked for 10ms, recorded as %+v", snap.Latency)
+	}
+	if snap.TotalWait < 20*time.Millisecond {
+		t.Fatalf("total wait %v, want at least 20ms", snap.TotalWait)
+	}
+}
+
+func TestInstrumentedPrometheus(t *testing.T) {
+	s := NewInstrumented("api-pool", NewSemaphore1(10, 2))
+	s.Acquire()
+	s.Acquire()
+
+	rec := httptest.NewRecorder()
+	PrometheusHandler(s).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE api_pool_holders gauge",
+		"api_pool_holders 2",
+		"api_pool_acquires_total 2",
+		"# TYPE api_pool_acquire_latency_seconds histogram",
+		`api_pool_acquire_latency_seconds_bucket{le="+Inf"} 2`,
+		"api_pool_acquire_latency_seconds_count 2",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+}
+
+func TestInstrumentedExpvar(t *testing.T) {
+	// expvar names cannot be reused, even by tests run again with -count
+	name := fmt.Sprintf("instrumented_test_%d", time.Now().UnixNano())
+	sem := NewSemaphore2(1)
+	defer sem.Close()
+	s := NewInstrumented(name, sem)
+	if err := s.PublishExpvar(); err != nil {
+		t.Fatal(err)
+	}
+	s.Acquire()
+
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Holders != 1 || snap.Acquires != 1 {
+		t.Fatalf("expvar published %+v", snap)
+	}
+
+	if err := NewInstrumented(name, sem).PublishExpvar(); err == nil {
+		t.Fatal("published the same name twice")
+	}
+}
@@ -0,0 +1,266 @@
+package semaphore
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Model is the sequential specification of a counting semaphore.
+// A history is linearizable if every operation in it can be given a single
+// point between its call and its return such that, taken in that order,
+// the operations are legal for Model.
+//
+//   - Acquire, and AcquireContext or TryAcquire that succeed, take a permit
+//     and are only legal while one is available
+//   - TryAcquire that fails is only legal while no permit is available
+//   - AcquireContext that fails has no effect
+//   - Release that succeeds adds a permit, and is only legal below Capacity
+//   - Release that fails with ErrOverRelease is only legal at Capacity,
+//     and any other failed Release has no effect
+//
+// Designs that turn TryAcquire away while others are queued are still
+// linearizable, as long as the queued waiters eventually take the permits:
+// they can be ordered before the TryAcquire.
+type Model struct {
+	Initial  int // Permits available at the start of the history
+	Capacity int // Most permits available at once, 0 for no limit
+}
+
+// step applies op to count, reporting whether op is legal in that state.
+// A pending op did not return, so it is tried as if it succeeded; it failing
+// is covered by leaving it out of the order altogether.
+func (m Model) step(count int, op Operation) (int, bool) {
+	switch op.Op {
+	case OpAcquire, OpAcquireContext, OpTryAcquire:
+		if op.Pending || op.Ok {
+			return count - 1, count > 0
+		}
+		if op.Op == OpTryAcquire {
+			return count, count == 0
+		}
+		return count, true
+
+	case OpRelease:
+		if op.Pending || op.Ok {
+			return count + 1, m.Capacity == 0 || count < m.Capacity
+		}
+		if errors.Is(op.Err, ErrOverRelease) {
+			return count, m.Capacity > 0 && count == m.Capacity
+		}
+		return count, true
+	}
+	return count, false
+}
+
+// Operation pairs the Invoke and Return events of a recorded call
+type Operation struct {
+	ID      int
+	Op      OpKind
+	Ok      bool
+	Err     error
+	Call    time.Time
+	Return  time.Time // Zero if Pending
+	Pending bool      // No Return event was recorded
+
+	call, ret int // Indices of the events in the history, ret < 0 if Pending
+}
+
+func (o Operation) String() string {
+	var result string
+	switch {
+	case o.Pending:
+		result = "..."
+	case o.Op == OpAcquire:
+		result = "ok"
+	case o.Op == OpTryAcquire:
+		result = fmt.Sprint(o.Ok)
+	default:
+		result = fmt.Sprint(o.Err)
+	}
+	return fmt.Sprintf("#%d %v() = %s", o.ID, o.Op, result)
+}
+
+// Operations pairs up the events of a history, in the order of their calls
+func Operations(history []Event) []Operation {
+	var ops []Operation
+	byID := make(map[int]int)
+	for i, e := range history {
+		switch e.Kind {
+		case Invoke:
+			byID[e.ID] = len(ops)
+			ops = append(ops, Operation{ID: e.ID, Op: e.Op, Call: e.Time, Pending: true, call: i, ret: -1})
+		case Return:
+			if j, ok := byID[e.ID]; ok {
+				o := &ops[j]
+				o.Ok, o.Err, o.Return, o.Pending, o.ret = e.Ok, e.Err, e.Time, false, i
+			}
+		}
+	}
+	return ops
+}
+
+// LinearizabilityViolation is a minimal part of a history that cannot be
+// linearized. It starts at the latest point where no operation was in
+// flight, with Initial permits available, and ends with the return of
+// Failed. Ops that had not returned by then are Pending.
+type LinearizabilityViolation struct {
+	Initial int
+	Ops     []Operation
+	Failed  Operation
+}
+
+func (v *LinearizabilityViolation) Error() string {
+	return fmt.Sprintf("history is not linearizable: %v cannot be ordered among %d operations starting from %d permits",
+		v.Failed, len(v.Ops), v.Initial)
+}
+
+// Timeline lists Ops with their call and return times,
+// relative to the first call
+func (v *LinearizabilityViolation) Timeline() string {
+	if len(v.Ops) == 0 {
+		return ""
+	}
+	start := v.Ops[0].Call
+	var b strings.Builder
+	for _, o := range v.Ops {
+		end := "in flight"
+		if !o.Pending {
+			end = o.Return.Sub(start).String()
+		}
+		fmt.Fprintf(&b, "%12v .. %-12s %v\n", o.Call.Sub(start), end, o)
+	}
+	return b.String()
+}
+
+// CheckLinearizable reports whether history, as logged by a Recorder, is
+// linearizable with respect to m. The returned error is a
+// *LinearizabilityViolation if it is not.
+//
+// The check is the Wing & Gong search with memoisation of visited states.
+// It is exponential in the number of operations in flight at once, not in
+// the length of the history: the history is split wherever no operation is
+// in flight, as the number of permits is known exactly at those points.
+func CheckLinearizable(m Model, history []Event) error {
+	count := m.Initial
+	start := 0
+	inFlight := 0
+	for i, e := range history {
+		if e.Kind == Invoke {
+			inFlight++
+		} else {
+			inFlight--
+		}
+		if inFlight > 0 && i < len(history)-1 {
+			continue
+		}
+		segment := history[start : i+1]
+		if !linearizable(m, count, Operations(segment)) {
+			return violation(m, count, segment)
+		}
+		for _, o := range Operations(segment) {
+			count, _ = m.step(count, o)
+		}
+		start = i + 1
+	}
+	return nil
+}
+
+// violation shrinks a segment that cannot be linearized to its shortest
+// prefix that cannot be linearized either. Cutting a history short only
+// makes operations pending, which loosens the check, so the prefixes that
+// fail are exactly those at least as long as the shortest one.
+func violation(m Model, initial int, segment []Event) error {
+	lo, hi := 0, len(segment) // segment[:lo] is linearizable, segment[:hi] is not
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if linearizable(m, initial, Operations(segment[:mid])) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	ops := Operations(segment[:hi])
+	v := &LinearizabilityViolation{Initial: initial, Ops: ops}
+	for _, o := range ops {
+		if o.ID == segment[hi-1].ID {
+			v.Failed = o
+		}
+	}
+	return v
+}
+
+// linearizable searches for an order of ops, starting from count permits,
+// in which every op that returned is legal. Pending ops may be left out.
+func linearizable(m Model, count int, ops []Operation) bool {
+	type entry struct {
+		op   int
+		call bool
+	}
+	entries := make([]entry, 0, 2*len(ops))
+	for i, o := range ops {
+		entries = append(entries, entry{i, true})
+		if !o.Pending {
+			entries = append(entries, entry{i, false})
+		}
+	}
+	// Put calls and returns back in the order they happened
+	pos := func(e entry) int {
+		if e.call {
+			return ops[e.op].call
+		}
+		return ops[e.op].ret
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return pos(a) - pos(b) })
+
+	left := 0
+	for _, o := range ops {
+		if !o.Pending {
+			left++
+		}
+	}
+
+	done := make([]byte, (len(ops)+7)/8)
+	seen := make(map[string]bool)
+	var search func(count, left int) bool
+	search = func(count, left int) bool {
+		if left == 0 {
+			return true
+		}
+		key := string(done) + strconv.Itoa(count)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+
+		// Any op called before the first return still to be ordered can go next
+		for _, e := range entries {
+			if done[e.op/8]&(1<<(e.op%8)) != 0 {
+				continue
+			}
+			if !e.call {
+				break
+			}
+			o := ops[e.op]
+			next, ok := m.step(count, o)
+			if !ok {
+				continue
+			}
+			done[e.op/8] |= 1 << (e.op % 8)
+			l := left
+			if !o.Pending {
+				l--
+			}
+			if search(next, l) {
+				return true
+			}
+			done[e.op/8] &^= 1 << (e.op % 8)
+		}
+		return false
+	}
+	return search(count, left)
+}
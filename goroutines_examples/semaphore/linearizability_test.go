@@ -0,0 +1,214 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// history builds a history from steps of the form {id, kind, op, ok}.
+// Each step happens a microsecond after the one before.
+type step struct {
+	id   int
+	kind EventKind
+	op   OpKind
+	ok   bool
+}
+
+func history(steps ...step) []Event {
+	start := time.Now()
+	events := make([]Event, len(steps))
+	for i, s := range steps {
+		events[i] = Event{Kind: s.kind, ID: s.id, Op: s.op, Time: start.Add(time.Duration(i) * time.Microsecond), Ok: s.ok}
+		if s.kind == Return && !s.ok && s.op != OpTryAcquire {
+			events[i].Err = context.Canceled
+		}
+	}
+	return events
+}
+
+// call is an operation that returns before the next one is called
+func call(id int, op OpKind, ok bool) []step {
+	return []step{{id, Invoke, op, false}, {id, Return, op, ok}}
+}
+
+func sequential(calls ...[]step) []Event {
+	var steps []step
+	for _, c := range calls {
+		steps = append(steps, c...)
+	}
+	return history(steps...)
+}
+
+func TestCheckLinearizable(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Model
+		h    []Event
+		ok   bool
+	}{
+		{"sequential", Model{Initial: 1}, sequential(
+			call(0, OpAcquire, true),
+			call(1, OpTryAcquire, false),
+			call(2, OpRelease, true),
+			call(3, OpTryAcquire, true),
+		), true},
+		{"two holders of one permit", Model{Initial: 1}, sequential(
+			call(0, OpAcquire, true),
+			call(1, OpAcquire, true),
+		), false},
+		{"TryAcquire failing with a permit", Model{Initial: 1}, sequential(
+			call(0, OpTryAcquire, false),
+		), false},
+		{"cancelled AcquireContext", Model{}, sequential(
+			call(0, OpAcquireContext, false),
+			call(1, OpRelease, true),
+			call(2, OpAcquireContext, true),
+		), true},
+		{"Acquire overlapping its Release", Model{}, history(
+			step{0, Invoke, OpAcquire, false},
+			step{1, Invoke, OpRelease, false},
+			step{1, Return, OpRelease, true},
+			step{0, Return, OpAcquire, true},
+		), true},
+		{"TryAcquire turned away by a queued waiter", Model{}, history(
+			step{0, Invoke, OpAcquire, false},
+			step{1, Invoke, OpRelease, false},
+			step{1, Return, OpRelease, true},
+			step{2, Invoke, OpTryAcquire, false},
+			step{2, Return, OpTryAcquire, false},
+			step{0, Return, OpAcquire, true},
+		), true},
+		{"TryAcquire turned away by a waiter that gave up", Model{}, history(
+			step{0, Invoke, OpAcquireContext, false},
+			step{1, Invoke, OpRelease, false},
+			step{1, Return, OpRelease, true},
+			step{2, Invoke, OpTryAcquire, false},
+			step{2, Return, OpTryAcquire, false},
+			step{0, Return, OpAcquireContext, false},
+		), false},
+		{"Acquire still blocked", Model{}, history(
+			step{0, Invoke, OpAcquire, false},
+			step{1, Invoke, OpTryAcquire, false},
+			step{1, Return, OpTryAcquire, false},
+		), true},
+		{"Release beyond capacity", Model{Initial: 1, Capacity: 1}, sequential(
+			call(0, OpRelease, true),
+		), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckLinearizable(tt.m, tt.h)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("history accepted")
+			}
+		})
+	}
+}
+
+func TestCheckLinearizableOverRelease(t *testing.T) {
+	h := sequential(call(0, OpRelease, false))
+	h[1].Err = ErrOverRelease
+	if err := CheckLinearizable(Model{Initial: 1, Capacity: 1}, h); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLinearizable(Model{Initial: 0, Capacity: 1}, h); err == nil {
+		t.Fatal("over-release below capacity accepted")
+	}
+}
+
+func TestLinearizabilityViolationIsMinimal(t *testing.T) {
+	// Many valid rounds, then two acquires of one permit, with a TryAcquire
+	// still in flight when the second returns
+	var calls [][]step
+	id := 0
+	for i := 0; i < 50; i++ {
+		calls = append(calls, call(id, OpAcquire, true), call(id+1, OpRelease, true))
+		id += 2
+	}
+	calls = append(calls, []step{
+		{id, Invoke, OpAcquire, false},
+		{id + 1, Invoke, OpAcquire, false},
+		{id + 2, Invoke, OpTryAcquire, false},
+		{id, Return, OpAcquire, true},
+		{id + 1, Return, OpAcquire, true},
+		{id + 2, Return, OpTryAcquire, false},
+		{id + 3, Invoke, OpRelease, false},
+		{id + 3, Return, OpRelease, true},
+	})
+
+	err := CheckLinearizable(Model{Initial: 1}, sequential(calls...))
+	var v *LinearizabilityViolation
+	if !errors.As(err, &v) {
+		t.Fatalf("CheckLinearizable = %v, want a violation", err)
+	}
+	if v.Initial != 1 || len(v.Ops) != 3 || v.Failed.ID != id+1 {
+		t.Fatalf("got %v\n%s", v, v.Timeline())
+	}
+	if !v.Ops[2].Pending {
+		t.Fatalf("TryAcquire should still be in flight in\n%s", v.Timeline())
+	}
+}
+
+func TestRecordedHistoriesLinearizable(t *testing.T) {
+	const permits, workers, rounds = 2, 4, 100
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			r := NewRecorder(impl.new(permits))
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < rounds; j++ {
+						if j%3 == 0 {
+							if !r.TryAcquire() {
+								continue
+							}
+						} else {
+							r.Acquire()
+						}
+						r.Release()
+					}
+				}()
+			}
+			wg.Wait()
+
+			m := Model{Initial: permits}
+			if impl.name != "Semaphore2" && impl.name != "PrioritySemaphore" {
+				m.Capacity = 1000
+			}
+			if err := CheckLinearizable(m, r.History()); err != nil {
+				var v *LinearizabilityViolation
+				errors.As(err, &v)
+				t.Fatalf("%v\n%s", err, v.Timeline())
+			}
+		})
+	}
+}
+
+func TestRecorderHistory(t *testing.T) {
+	r := NewRecorder(NewSemaphore1(1, 0))
+	r.TryAcquire()
+	r.Release()
+	r.AcquireContext(context.Background())
+
+	ops := Operations(r.History())
+	want := []string{"#0 TryAcquire() = false", "#1 Release() = <nil>", "#2 AcquireContext() = <nil>"}
+	if len(ops) != len(want) {
+		t.Fatalf("got %d operations, want %d", len(ops), len(want))
+	}
+	for i, o := range ops {
+		if o.Pending || o.String() != want[i] {
+			t.Errorf("operation %d = %v, want %s", i, o, want[i])
+		}
+		if o.Return.Before(o.Call) {
+			t.Errorf("operation %d returned before it was called", i)
+		}
+	}
+}
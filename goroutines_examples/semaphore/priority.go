@@ -0,0 +1,254 @@
+package semaphore
+
+import (
+	"container/heap"
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/internal/cleanup"
+)
+
+// A queued waiter of PrioritySemaphore
+type priorityWaiter struct {
+	ch       chan struct{}
+	priority int
+	seq      uint64        // Arrival order, to break ties
+	arrival  time.Duration // Arrival time, shifted back by priority*aging
+	index    int           // Position in the heap
+}
+
+// Heap of waiters, the one to unblock next at the top
+type waiterHeap struct {
+	waiters []*priorityWaiter
+	aging   time.Duration
+}
+
+func (h *waiterHeap) Len() int { return len(h.waiters) }
+
+func (h *waiterHeap) Less(i, j int) bool {
+	a, b := h.waiters[i], h.waiters[j]
+	if h.aging > 0 {
+		if a.arrival != b.arrival {
+			return a.arrival < b.arrival
+		}
+	} else if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (h *waiterHeap) Swap(i, j int) {
+	h.waiters[i], h.waiters[j] = h.waiters[j], h.waiters[i]
+	h.waiters[i].index = i
+	h.waiters[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*priorityWaiter)
+	w.index = len(h.waiters)
+	h.waiters = append(h.waiters, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	n := len(h.waiters)
+	w := h.waiters[n-1]
+	h.waiters[n-1] = nil
+	h.waiters = h.waiters[:n-1]
+	return w
+}
+
+type priorityRequest struct {
+	kind     int
+	priority int
+	ch       chan struct{}
+}
+
+// Daemon semaphore like Semaphore2, but the daemon unblocks the waiter with
+// the highest priority, and the oldest one among those with equal priority.
+//
+// With aging, a waiter gains one level of priority for every aging it has
+// spent queued, so low priority waiters are eventually served however many
+// high priority waiters keep arriving. This is the same as treating a waiter
+// of priority p as if it had arrived p*aging earlier, which keeps the order
+// of queued waiters fixed and lets the daemon use a plain heap.
+type PrioritySemaphore struct {
+	requestCh chan priorityRequest
+
+	cancel context.CancelFunc
+	closed atomic.Bool
+	done   chan struct{} // Closed once the daemon has exited
+}
+
+// NewPrioritySemaphore starts the daemon of a priority semaphore.
+// aging is how long a waiter must wait to gain one level of priority,
+// and zero disables aging.
+func NewPrioritySemaphore(initial_count int, aging time.Duration) *PrioritySemaphore {
+	sem := new(PrioritySemaphore)
+	sem.requestCh = make(chan priorityRequest, 100)
+	sem.done = make(chan struct{})
+	var ctx context.Context
+	ctx, sem.cancel = context.WithCancel(context.Background())
+
+	// The daemon must not reference sem, so that sem can be garbage
+	// collected once users drop it, which stops the daemon
+	requestCh, done := sem.requestCh, sem.done
+	go func() {
+		count := initial_count
+		start := time.Now()
+		var seq uint64
+		waiters := &waiterHeap{aging: aging}
+		queued := make(map[chan struct{}]*priorityWaiter)
+
+		for {
+			select {
+			case req := <-requestCh:
+				switch req.kind {
+				case releaseReq: // Increment or unblock the best waiter
+					if waiters.Len() > 0 {
+						w := heap.Pop(waiters).(*priorityWaiter)
+						delete(queued, w.ch)
+						w.ch <- struct{}{}
+					} else {
+						count++
+					}
+
+				case acquireReq: // Decrement or add a waiter
+					if count > 0 {
+						count--
+						req.ch <- struct{}{}
+					} else {
+						seq++
+						w := &priorityWaiter{
+							ch:       req.ch,
+							priority: req.priority,
+							seq:      seq,
+							arrival:  time.Since(start) - time.Duration(req.priority)*aging,
+						}
+						heap.Push(waiters, w)
+						queued[w.ch] = w
+					}
+
+				case tryAcquireReq: // Decrement or turn the caller away
+					if count > 0 {
+						count--
+						req.ch <- struct{}{}
+					}
+					close(req.ch)
+
+				case cancelReq: // Waiter gave up
+					if w, ok := queued[req.ch]; ok {
+						heap.Remove(waiters, w.index)
+						delete(queued, w.ch)
+					}
+					close(req.ch)
+				}
+
+			case <-ctx.Done(): // Shut down
+				for _, w := range waiters.waiters {
+					close(w.ch)
+				}
+				close(done)
+				return
+			}
+		}
+	}()
+
+	cleanup.StopWhenUnreachable(sem, sem.cancel)
+	return sem
+}
+
+// Acquire waits with priority 0.
+// It panics with ErrClosed if the semaphore is shut down before a permit is taken.
+func (s *PrioritySemaphore) Acquire() {
+	if err := s.AcquirePriority(context.Background(), 0); err != nil {
+		panic(err)
+	}
+}
+
+func (s *PrioritySemaphore) TryAcquire() bool {
+	defer runtime.KeepAlive(s)
+	ch := make(chan struct{}, 1)
+	select {
+	case s.requestCh <- priorityRequest{tryAcquireReq, 0, ch}:
+	case <-s.done:
+		return false
+	}
+	return reply(ch, s.done)
+}
+
+// AcquireContext waits with priority 0 until a permit is taken or ctx is done
+func (s *PrioritySemaphore) AcquireContext(ctx context.Context) error {
+	return s.AcquirePriority(ctx, 0)
+}
+
+// AcquirePriority waits with the given priority, higher first, until a
+// permit is taken or ctx is done. A waiter that gives up is removed from
+// the daemon's queue, and a permit sent to it meanwhile is released again.
+func (s *PrioritySemaphore) AcquirePriority(ctx context.Context, priority int) error {
+	defer runtime.KeepAlive(s) // Don't stop the daemon while we wait
+	ch := make(chan struct{}, 1)
+	select {
+	case s.requestCh <- priorityRequest{acquireReq, priority, ch}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrClosed
+	}
+
+	select {
+	case _, ok := <-ch:
+		if !ok {
+			return ErrClosed
+		}
+		return nil
+	case <-s.done:
+		if !reply(ch, s.done) {
+			return ErrClosed
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	select {
+	case s.requestCh <- priorityRequest{cancelReq, priority, ch}:
+	case <-s.done:
+	}
+	if reply(ch, s.done) {
+		s.Release()
+	}
+	return ctx.Err()
+}
+
+// AcquirePriorityDeadline is AcquirePriority, giving up at deadline
+func (s *PrioritySemaphore) AcquirePriorityDeadline(ctx context.Context, priority int, deadline time.Time) error {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	return s.AcquirePriority(ctx, priority)
+}
+
+func (s *PrioritySemaphore) Release() error {
+	select {
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case s.requestCh <- priorityRequest{kind: releaseReq}:
+		return nil
+	case <-s.done:
+		return ErrClosed
+	}
+}
+
+// Close stops the daemon and waits for it to exit.
+// Waiters still queued are woken up with ErrClosed.
+func (s *PrioritySemaphore) Close() error {
+	if s.closed.Swap(true) {
+		return ErrClosed
+	}
+	s.cancel()
+	<-s.done
+	return nil
+}
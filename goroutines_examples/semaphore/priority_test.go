@@ -0,0 +1,131 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// grantOrder queues one waiter per priority, settle apart, then releases
+// one permit at a time and returns the priorities in the order granted
+func grantOrder(t *testing.T, s *PrioritySemaphore, priorities []int, settle time.Duration) []int {
+	t.Helper()
+	var mu sync.Mutex
+	var order []int
+	granted := make(chan struct{})
+	for _, p := range priorities {
+		go func() {
+			if err := s.AcquirePriority(context.Background(), p); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			order = append(order, p)
+			mu.Unlock()
+			granted <- struct{}{}
+		}()
+		time.Sleep(settle)
+	}
+	for range priorities {
+		s.Release()
+		select {
+		case <-granted:
+		case <-time.After(time.Second):
+			t.Fatal("Release did not unblock any waiter")
+		}
+	}
+	return order
+}
+
+func TestPrioritySemaphoreOrder(t *testing.T) {
+	s := NewPrioritySemaphore(0, 0)
+	defer s.Close()
+	got := grantOrder(t, s, []int{0, 5, 2, 5, -1}, 5*time.Millisecond)
+	want := []int{5, 5, 2, 0, -1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("granted priorities %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPrioritySemaphoreAging(t *testing.T) {
+	// After 100ms a priority 0 waiter has aged past priority 5
+	s := NewPrioritySemaphore(0, 10*time.Millisecond)
+	defer s.Close()
+	got := grantOrder(t, s, []int{0, 5}, 100*time.Millisecond)
+	if got[0] != 0 {
+		t.Fatalf("granted priorities %v, want the aged waiter first", got)
+	}
+
+	// Without enough time to age, priority still wins
+	got = grantOrder(t, s, []int{0, 5}, 5*time.Millisecond)
+	if got[0] != 5 {
+		t.Fatalf("granted priorities %v, want the higher priority first", got)
+	}
+}
+
+func TestPrioritySemaphoreDeadline(t *testing.T) {
+	s := NewPrioritySemaphore(0, 0)
+	defer s.Close()
+	err := s.AcquirePriorityDeadline(context.Background(), 10, time.Now().Add(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AcquirePriorityDeadline = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The expired waiter left the queue and does not take the next permit
+	s.Release()
+	if !s.TryAcquire() {
+		t.Fatal("permit was given to an expired waiter")
+	}
+}
+
+func TestPrioritySemaphoreTryAcquireSeesRelease(t *testing.T) {
+	s := NewPrioritySemaphore(0, 0)
+	defer s.Close()
+	for i := 0; i < 1000; i++ {
+		s.Release()
+		if !s.TryAcquire() {
+			t.Fatalf("round %d: TryAcquire failed right after Release returned", i)
+		}
+	}
+}
+
+func TestPrioritySemaphoreDroppedStopsDaemon(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		NewPrioritySemaphore(1, 0).Acquire()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after dropping the semaphores, want %d", runtime.NumGoroutine(), baseline)
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrioritySemaphoreWaiterKeepsDaemon(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func(s *PrioritySemaphore) { errCh <- s.AcquirePriority(ctx, 1) }(NewPrioritySemaphore(0, 0))
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("waiter returned %v while still waiting", err)
+	default:
+	}
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("AcquirePriority = %v, want %v", err, context.Canceled)
+	}
+}
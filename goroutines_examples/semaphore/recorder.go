@@ -0,0 +1,119 @@
+package semaphore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OpKind is a SemaphoreInterface method
+type OpKind int
+
+const (
+	OpAcquire OpKind = iota
+	OpTryAcquire
+	OpAcquireContext
+	OpRelease
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpAcquire:
+		return "Acquire"
+	case OpTryAcquire:
+		return "TryAcquire"
+	case OpAcquireContext:
+		return "AcquireContext"
+	case OpRelease:
+		return "Release"
+	}
+	return "OpKind(?)"
+}
+
+// EventKind tells whether an Event is the call or the return of an operation
+type EventKind int
+
+const (
+	Invoke EventKind = iota
+	Return
+)
+
+// An Event is the call or the return of one operation on a recorded semaphore
+type Event struct {
+	Kind EventKind
+	ID   int // Operation the event belongs to, shared by its Invoke and Return
+	Op   OpKind
+	Time time.Time
+
+	// Set on Return only
+	Ok  bool  // TryAcquire's result, or whether Err is nil
+	Err error // Error returned by AcquireContext or Release
+}
+
+// Recorder wraps a semaphore and logs an Invoke event before, and a Return
+// event after, every call made through it. Events are logged under a mutex,
+// so History lists them in the order they really happened: an operation
+// whose Return comes before another's Invoke finished before it started.
+//
+// An operation that never returns, such as an Acquire still blocked, or one
+// that panicked, only has an Invoke event.
+type Recorder struct {
+	sem SemaphoreInterface
+
+	mu     sync.Mutex
+	events []Event
+	nextID int
+}
+
+func NewRecorder(sem SemaphoreInterface) *Recorder {
+	return &Recorder{sem: sem}
+}
+
+func (r *Recorder) invoke(op OpKind) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.events = append(r.events, Event{Kind: Invoke, ID: id, Op: op, Time: time.Now()})
+	return id
+}
+
+func (r *Recorder) ret(id int, op OpKind, ok bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{Return, id, op, time.Now(), ok, err})
+}
+
+func (r *Recorder) Acquire() {
+	id := r.invoke(OpAcquire)
+	r.sem.Acquire()
+	r.ret(id, OpAcquire, true, nil)
+}
+
+func (r *Recorder) TryAcquire() bool {
+	id := r.invoke(OpTryAcquire)
+	ok := r.sem.TryAcquire()
+	r.ret(id, OpTryAcquire, ok, nil)
+	return ok
+}
+
+func (r *Recorder) AcquireContext(ctx context.Context) error {
+	id := r.invoke(OpAcquireContext)
+	err := r.sem.AcquireContext(ctx)
+	r.ret(id, OpAcquireContext, err == nil, err)
+	return err
+}
+
+func (r *Recorder) Release() error {
+	id := r.invoke(OpRelease)
+	err := r.sem.Release()
+	r.ret(id, OpRelease, err == nil, err)
+	return err
+}
+
+// History returns a copy of the events logged so far
+func (r *Recorder) History() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
@@ -0,0 +1,195 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// Client is a connection to a Server. It is safe for concurrent use, and
+// requests from different goroutines are multiplexed over the connection.
+type Client struct {
+	nc net.Conn
+
+	wmu sync.Mutex // Serialises requests
+	enc *json.Encoder
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan response // Requests waiting for a reply, by ID
+	done    chan struct{}            // Closed once the connection is lost
+}
+
+// Dial connects to a Server listening on network and address
+func Dial(network, address string) (*Client, error) {
+	nc, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		nc:      nc,
+		enc:     json.NewEncoder(nc),
+		pending: make(map[uint64]chan response),
+		done:    make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+// read hands every reply to the request waiting for it
+func (c *Client) read() {
+	dec := json.NewDecoder(bufio.NewReader(c.nc))
+	for {
+		var resp response
+		if err := dec.Decode(&resp); err != nil {
+			break
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+	c.nc.Close()
+	close(c.done)
+}
+
+// send sends req with a fresh ID, returning the ID and the channel its
+// reply will arrive on
+func (c *Client) send(req request) (uint64, chan response, error) {
+	ch := make(chan response, 1)
+	c.mu.Lock()
+	c.nextID++
+	req.ID = c.nextID
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+
+	c.wmu.Lock()
+	err := c.enc.Encode(req)
+	c.wmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+		return 0, nil, semaphore.ErrClosed
+	}
+	return req.ID, ch, nil
+}
+
+// wait waits for the reply on ch, or for the connection to be lost
+func (c *Client) wait(ch chan response) (response, error) {
+	select {
+	case resp := <-ch:
+		return resp, decodeErr(resp.Err)
+	case <-c.done:
+		select {
+		case resp := <-ch:
+			return resp, decodeErr(resp.Err)
+		default:
+			return response{}, semaphore.ErrClosed
+		}
+	}
+}
+
+func (c *Client) call(req request) (response, error) {
+	_, ch, err := c.send(req)
+	if err != nil {
+		return response{}, err
+	}
+	return c.wait(ch)
+}
+
+// acquire takes a permit, blocking until it is granted or ctx is done.
+// A request that is given up is cancelled on the server, and a permit
+// granted to it meanwhile is released again.
+func (c *Client) acquire(ctx context.Context, req request) (uint64, error) {
+	id, ch, err := c.send(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp response
+	select {
+	case resp = <-ch:
+		return resp.Lease, decodeErr(resp.Err)
+	case <-c.done:
+		return 0, semaphore.ErrClosed
+	case <-ctx.Done():
+	}
+
+	c.wmu.Lock()
+	c.enc.Encode(request{ID: id, Op: opCancel})
+	c.wmu.Unlock()
+	resp, err = c.wait(ch)
+	if err == nil {
+		c.call(request{Op: opRelease, Name: req.Name, Lease: resp.Lease})
+	}
+	return 0, ctx.Err()
+}
+
+// Close disconnects from the server, which expires every lease
+// taken through this client
+func (c *Client) Close() error {
+	err := c.nc.Close()
+	<-c.done
+	return err
+}
+
+// Semaphore returns the semaphore called name on the server
+func (c *Client) Semaphore(name string) *Semaphore {
+	return &Semaphore{c, name}
+}
+
+// Semaphore is a named semaphore on a Server. Permits it takes are leases
+// of the Client's connection, and are given back if the connection is lost.
+type Semaphore struct {
+	c    *Client
+	name string
+}
+
+var _ semaphore.SemaphoreInterface = (*Semaphore)(nil)
+
+// Acquire panics with the error if the connection is lost,
+// or the semaphore is unknown or shut down
+func (s *Semaphore) Acquire() {
+	if err := s.AcquireContext(context.Background()); err != nil {
+		panic(err)
+	}
+}
+
+func (s *Semaphore) AcquireContext(ctx context.Context) error {
+	_, err := s.c.acquire(ctx, request{Op: opAcquire, Name: s.name})
+	return err
+}
+
+func (s *Semaphore) TryAcquire() bool {
+	resp, err := s.c.call(request{Op: opTry, Name: s.name})
+	return err == nil && resp.Ok
+}
+
+// Release gives back the oldest permit this client holds on the semaphore,
+// failing with ErrLeaseExpired if it holds none
+func (s *Semaphore) Release() error {
+	_, err := s.c.call(request{Op: opRelease, Name: s.name})
+	return err
+}
+
+// Lease takes a permit that the server releases by itself after ttl,
+// unless it is given back earlier with ReleaseLease
+func (s *Semaphore) Lease(ctx context.Context, ttl time.Duration) (uint64, error) {
+	return s.c.acquire(ctx, request{Op: opLease, Name: s.name, TTL: ttl})
+}
+
+// ReleaseLease gives back the permit taken with the given lease,
+// failing with ErrLeaseExpired if the server has already released it
+func (s *Semaphore) ReleaseLease(lease uint64) error {
+	_, err := s.c.call(request{Op: opRelease, Name: s.name, Lease: lease})
+	return err
+}
@@ -0,0 +1,100 @@
+// Package remote serves named semaphores over a local socket, so that
+// goroutines in different processes can share them.
+//
+// A Server keeps one Semaphore2 daemon per name and speaks the same
+// request/response protocol to clients that Semaphore2 speaks to its
+// waiters, one JSON message per line:
+//
+//	{"id":1,"op":"acquire","name":"db"}                 -> {"id":1,"ok":true,"lease":7}
+//	{"id":2,"op":"try","name":"db"}                     -> {"id":2,"ok":false}
+//	{"id":3,"op":"lease","name":"db","ttl":1000000000}  -> {"id":3,"ok":true,"lease":8}
+//	{"id":4,"op":"release","name":"db","lease":8}       -> {"id":4,"ok":true}
+//	{"id":1,"op":"cancel"}                              (no reply of its own)
+//
+// A ttl is an integer number of nanoseconds. A line that is not a valid
+// request gets an error reply, with the ID of the request if it has one,
+// and the connection carries on.
+//
+// Every permit a client takes is a lease held by its connection. A lease
+// taken with "lease" expires after its ttl, and all leases of a connection
+// expire when it disconnects, so a crashed client cannot keep permits.
+//
+// Client dials a Server, and Client.Semaphore returns a Semaphore that
+// satisfies semaphore.SemaphoreInterface.
+package remote
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// Kinds of requests a client can make
+const (
+	opAcquire = "acquire"
+	opTry     = "try"
+	opLease   = "lease"
+	opRelease = "release"
+	opCancel  = "cancel"
+)
+
+type request struct {
+	ID    uint64        `json:"id"`
+	Op    string        `json:"op"`
+	Name  string        `json:"name,omitempty"`
+	TTL   time.Duration `json:"ttl,omitempty"`   // Lease only
+	Lease uint64        `json:"lease,omitempty"` // Release of a given lease only
+}
+
+type response struct {
+	ID    uint64 `json:"id"`
+	Ok    bool   `json:"ok"`
+	Lease uint64 `json:"lease,omitempty"` // Lease granted to acquire and lease
+	Err   string `json:"err,omitempty"`
+}
+
+// ErrUnknownSemaphore is returned for names the server was not given
+var ErrUnknownSemaphore = errors.New("remote: unknown semaphore")
+
+// ErrLeaseExpired is returned when releasing a lease that has expired
+// or was never granted, or releasing with no lease held
+var ErrLeaseExpired = errors.New("remote: lease expired")
+
+// ErrDuplicateID is returned for an acquire or lease whose request ID is
+// already used by another acquire in flight on the same connection
+var ErrDuplicateID = errors.New("remote: request ID already in flight")
+
+// ErrNotLocal is returned when asked to listen on anything but a Unix
+// socket or a loopback TCP address
+var ErrNotLocal = errors.New("remote: not a local address")
+
+// Errors that keep their identity across the wire
+var wireErrors = []error{
+	semaphore.ErrClosed,
+	semaphore.ErrOverRelease,
+	ErrUnknownSemaphore,
+	ErrLeaseExpired,
+	ErrDuplicateID,
+	context.Canceled,
+}
+
+func encodeErr(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func decodeErr(s string) error {
+	if s == "" {
+		return nil
+	}
+	for _, err := range wireErrors {
+		if err.Error() == s {
+			return err
+		}
+	}
+	return errors.New(s)
+}
@@ -0,0 +1,286 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// serve starts a server on a Unix socket and returns its address
+func serve(t *testing.T, sems map[string]int) string {
+	t.Helper()
+	addr := filepath.Join(t.TempDir(), "sem.sock")
+	l, err := Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(sems)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return addr
+}
+
+func dial(t *testing.T, addr string) *Client {
+	t.Helper()
+	c, err := Dial("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// acquired reports whether AcquireContext succeeds within d
+func acquired(s *Semaphore, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.AcquireContext(ctx) == nil
+}
+
+func TestRemoteSemaphore(t *testing.T) {
+	s := dial(t, serve(t, map[string]int{"db": 2})).Semaphore("db")
+
+	if !acquired(s, time.Second) || !s.TryAcquire() {
+		t.Fatal("could not take the 2 initial permits")
+	}
+	if s.TryAcquire() {
+		t.Fatal("TryAcquire succeeded with no permits available")
+	}
+	if acquired(s, 50*time.Millisecond) {
+		t.Fatal("Acquire did not block with no permits available")
+	}
+
+	// The cancelled waiter must not swallow the next Release
+	if err := s.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if !acquired(s, time.Second) {
+		t.Fatal("Release did not make a permit available")
+	}
+}
+
+func TestRemoteWaiterUnblocked(t *testing.T) {
+	addr := serve(t, map[string]int{"db": 1})
+	waiter := dial(t, addr).Semaphore("db")
+	releaser := dial(t, addr).Semaphore("db")
+	releaser.Acquire()
+
+	done := make(chan struct{})
+	go func() {
+		waiter.Acquire()
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	releaser.Release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Release from another client did not unblock the waiter")
+	}
+}
+
+func TestRemoteDisconnectExpiresLeases(t *testing.T) {
+	addr := serve(t, map[string]int{"db": 2})
+	holder, err := Dial("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := holder.Semaphore("db")
+	s.Acquire()
+	if _, err := s.Lease(context.Background(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	other := dial(t, addr).Semaphore("db")
+	if other.TryAcquire() {
+		t.Fatal("TryAcquire succeeded while another client held every permit")
+	}
+	holder.Close()
+	for i := 0; i < 2; i++ {
+		if !acquired(other, time.Second) {
+			t.Fatalf("permit %d was not given back on disconnect", i)
+		}
+	}
+}
+
+func TestRemoteLeaseExpires(t *testing.T) {
+	s := dial(t, serve(t, map[string]int{"db": 1})).Semaphore("db")
+	lease, err := s.Lease(context.Background(), 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acquired(s, time.Second) {
+		t.Fatal("lease did not expire")
+	}
+	if err := s.ReleaseLease(lease); !errors.Is(err, ErrLeaseExpired) {
+		t.Fatalf("ReleaseLease = %v, want %v", err, ErrLeaseExpired)
+	}
+
+	// A lease given back in time does not expire later
+	s.Release()
+	lease, _ = s.Lease(context.Background(), 50*time.Millisecond)
+	if err := s.ReleaseLease(lease); err != nil {
+		t.Fatal(err)
+	}
+	s.Acquire()
+	time.Sleep(100 * time.Millisecond)
+	if s.TryAcquire() {
+		t.Fatal("a released lease expired again")
+	}
+}
+
+func TestRemoteReleaseAfterExpiry(t *testing.T) {
+	s := dial(t, serve(t, map[string]int{"db": 1})).Semaphore("db")
+	if _, err := s.Lease(context.Background(), 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	// The expired lease was the only permit the client held
+	if err := s.Release(); !errors.Is(err, ErrLeaseExpired) {
+		t.Fatalf("Release after expiry = %v, want %v", err, ErrLeaseExpired)
+	}
+
+	// The capacity is still 1
+	if !s.TryAcquire() {
+		t.Fatal("the expired lease did not give its permit back")
+	}
+	if s.TryAcquire() {
+		t.Fatal("Release after expiry added a permit")
+	}
+}
+
+func TestRemoteBadRequests(t *testing.T) {
+	nc, err := net.Dial("unix", serve(t, map[string]int{"db": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	dec := json.NewDecoder(nc)
+	expect := func(line string, id uint64, want string) {
+		t.Helper()
+		if _, err := nc.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var resp response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("%s: connection lost: %v", line, err)
+		}
+		if resp.ID != id || resp.Ok || !strings.Contains(resp.Err, want) {
+			t.Fatalf("%s = %+v, want ID %d and an error with %q", line, resp, id, want)
+		}
+	}
+
+	expect(`{"id":1,"op":"lease","name":"db","ttl":1e9}`, 1, "bad request")
+	expect(`{"id":2,"op":`, 0, "bad request")
+	expect(`{"id":3,"op":"bogus"}`, 3, "unknown op")
+	expect(`{"id":4,"op":"acquire","name":"cache"}`, 4, ErrUnknownSemaphore.Error())
+
+	// The connection still serves well-formed requests, as documented
+	if _, err := nc.Write([]byte(`{"id":5,"op":"lease","name":"db","ttl":1000000000}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	var resp response
+	if err := dec.Decode(&resp); err != nil || resp.ID != 5 || !resp.Ok {
+		t.Fatalf("lease after bad requests = %+v, %v", resp, err)
+	}
+}
+
+func TestRemoteDuplicateID(t *testing.T) {
+	nc, err := net.Dial("unix", serve(t, map[string]int{"db": 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	enc, dec := json.NewEncoder(nc), json.NewDecoder(nc)
+
+	// Both wait, as there are no permits
+	enc.Encode(request{ID: 1, Op: opAcquire, Name: "db"})
+	enc.Encode(request{ID: 1, Op: opAcquire, Name: "db"})
+	var resp response
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 1 || decodeErr(resp.Err) != ErrDuplicateID {
+		t.Fatalf("second acquire with ID 1 = %+v, want %v", resp, ErrDuplicateID)
+	}
+
+	// The first acquire is still waiting, and can be cancelled
+	enc.Encode(request{ID: 1, Op: opCancel})
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 1 || decodeErr(resp.Err) != context.Canceled {
+		t.Fatalf("cancelled acquire = %+v, want %v", resp, context.Canceled)
+	}
+
+	// Its ID can be used again once it is done
+	enc.Encode(request{ID: 1, Op: opTry, Name: "db"})
+	resp = response{}
+	if err := dec.Decode(&resp); err != nil || resp.ID != 1 || resp.Err != "" {
+		t.Fatalf("try after the acquire = %+v, %v", resp, err)
+	}
+}
+
+func TestRemoteErrors(t *testing.T) {
+	addr := serve(t, map[string]int{"db": 1})
+	c := dial(t, addr)
+	if err := c.Semaphore("cache").AcquireContext(context.Background()); !errors.Is(err, ErrUnknownSemaphore) {
+		t.Fatalf("AcquireContext = %v, want %v", err, ErrUnknownSemaphore)
+	}
+
+	c.Close()
+	if err := c.Semaphore("db").Release(); !errors.Is(err, semaphore.ErrClosed) {
+		t.Fatalf("Release after Close = %v, want %v", err, semaphore.ErrClosed)
+	}
+
+	if _, err := Listen("tcp", "192.0.2.1:0"); !errors.Is(err, ErrNotLocal) {
+		t.Fatalf("Listen on a remote address = %v, want %v", err, ErrNotLocal)
+	}
+}
+
+func TestRemoteLinearizable(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(map[string]int{"db": 2})
+	go srv.Serve(l)
+	defer srv.Close()
+
+	c, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rec := semaphore.NewRecorder(c.Semaphore("db"))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if j%3 == 0 {
+					if !rec.TryAcquire() {
+						continue
+					}
+				} else {
+					rec.Acquire()
+				}
+				rec.Release()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := semaphore.CheckLinearizable(semaphore.Model{Initial: 2}, rec.History()); err != nil {
+		t.Fatal(err)
+	}
+}
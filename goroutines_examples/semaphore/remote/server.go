@@ -0,0 +1,356 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+// Server exposes a fixed set of named semaphores to clients
+type Server struct {
+	sems map[string]*semaphore.Semaphore2
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	nextLease uint64
+	closed    bool
+	wg        sync.WaitGroup // Connection handlers
+}
+
+// NewServer creates a server with one semaphore per entry of sems,
+// each starting with the given number of permits
+func NewServer(sems map[string]int) *Server {
+	s := &Server{
+		sems:      make(map[string]*semaphore.Semaphore2, len(sems)),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+	}
+	for name, initial_count := range sems {
+		s.sems[name] = semaphore.NewSemaphore2(initial_count)
+	}
+	return s
+}
+
+// Listen listens on a Unix socket, or on a loopback TCP address
+func Listen(network, address string) (net.Listener, error) {
+	switch network {
+	case "unix":
+	case "tcp", "tcp4", "tcp6":
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("%w: %s", ErrNotLocal, address)
+		}
+	default:
+		return nil, fmt.Errorf("%w: network %s", ErrNotLocal, network)
+	}
+	return net.Listen(network, address)
+}
+
+// ListenAndServe is Listen followed by Serve
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts clients on l until l fails or the server is closed.
+// It returns nil once the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return semaphore.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		c := &conn{
+			srv:      s,
+			nc:       nc,
+			enc:      json.NewEncoder(nc),
+			pending:  make(map[uint64]context.CancelFunc),
+			holdings: make(map[uint64]*holding),
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting clients, disconnects the connected ones, which
+// gives up their leases, and shuts the semaphores down
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return semaphore.ErrClosed
+	}
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.nc.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	for _, sem := range s.sems {
+		sem.Close()
+	}
+	return nil
+}
+
+func (s *Server) newLease() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextLease++
+	return s.nextLease
+}
+
+// A holding is a permit taken by a connection
+type holding struct {
+	id    uint64
+	sem   *semaphore.Semaphore2
+	timer *time.Timer // Nil for leases that only expire on disconnect
+}
+
+// conn serves one client
+type conn struct {
+	srv *Server
+	nc  net.Conn
+
+	wmu sync.Mutex // Serialises replies
+	enc *json.Encoder
+
+	mu       sync.Mutex
+	closed   bool
+	pending  map[uint64]context.CancelFunc // Acquires in flight, by request ID
+	holdings map[uint64]*holding           // Permits held, by lease ID
+	wg       sync.WaitGroup                // Acquires in flight
+}
+
+func (c *conn) reply(resp response) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.enc.Encode(resp) // A broken connection is noticed by the reader
+}
+
+// Longest request line a connection accepts. A longer one ends the
+// connection, as the end of the request cannot be found.
+const maxRequest = 64 << 10
+
+func (c *conn) serve() {
+	// Requests are decoded line by line, so that a malformed one is
+	// skipped instead of leaving the decoder stuck in the middle of it
+	sc := bufio.NewScanner(c.nc)
+	sc.Buffer(make([]byte, 0, 4096), maxRequest)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			// The ID is usually decoded even if another field is not
+			c.reply(response{ID: req.ID, Err: fmt.Sprintf("remote: bad request: %v", err)})
+			continue
+		}
+		c.handle(req)
+	}
+	c.disconnect()
+}
+
+func (c *conn) handle(req request) {
+	switch req.Op {
+	case opAcquire, opLease, opTry, opRelease, opCancel:
+	default:
+		c.reply(response{ID: req.ID, Err: fmt.Sprintf("remote: unknown op %q", req.Op)})
+		return
+	}
+
+	if req.Op == opCancel {
+		c.mu.Lock()
+		if cancel, ok := c.pending[req.ID]; ok {
+			cancel()
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	sem, ok := c.srv.sems[req.Name]
+	if !ok {
+		c.reply(response{ID: req.ID, Err: encodeErr(ErrUnknownSemaphore)})
+		return
+	}
+
+	switch req.Op {
+	case opAcquire, opLease:
+		c.mu.Lock()
+		if _, ok := c.pending[req.ID]; ok {
+			// A cancel could not tell the two acquires apart
+			c.mu.Unlock()
+			c.reply(response{ID: req.ID, Err: encodeErr(ErrDuplicateID)})
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		c.pending[req.ID] = cancel
+		c.wg.Add(1)
+		c.mu.Unlock()
+		go c.acquire(ctx, req, sem)
+
+	case opTry:
+		resp := response{ID: req.ID}
+		if sem.TryAcquire() {
+			c.mu.Lock()
+			resp.Ok, resp.Lease = true, c.hold(sem, 0)
+			c.mu.Unlock()
+		}
+		c.reply(resp)
+
+	case opRelease:
+		err := c.release(sem, req.Lease)
+		c.reply(response{ID: req.ID, Ok: err == nil, Err: encodeErr(err)})
+	}
+}
+
+// acquire waits for a permit in the background, so that the connection
+// can keep serving other requests, including the cancel of this one
+func (c *conn) acquire(ctx context.Context, req request, sem *semaphore.Semaphore2) {
+	defer c.wg.Done()
+	err := sem.AcquireContext(ctx)
+
+	c.mu.Lock()
+	if cancel, ok := c.pending[req.ID]; ok {
+		cancel()
+	}
+	delete(c.pending, req.ID)
+	if err == nil && c.closed {
+		// The client is gone, nobody will hold the permit
+		c.mu.Unlock()
+		sem.Release()
+		return
+	}
+	resp := response{ID: req.ID, Ok: err == nil, Err: encodeErr(err)}
+	if err == nil {
+		resp.Lease = c.hold(sem, req.TTL)
+	}
+	c.mu.Unlock()
+	c.reply(resp)
+}
+
+// hold records a permit taken by the connection, and returns its lease ID.
+// The caller must hold c.mu.
+func (c *conn) hold(sem *semaphore.Semaphore2, ttl time.Duration) uint64 {
+	h := &holding{id: c.srv.newLease(), sem: sem}
+	if ttl > 0 {
+		h.timer = time.AfterFunc(ttl, func() { c.expire(h.id) })
+	}
+	c.holdings[h.id] = h
+	return h.id
+}
+
+func (c *conn) expire(id uint64) {
+	c.mu.Lock()
+	h, ok := c.holdings[id]
+	delete(c.holdings, id)
+	c.mu.Unlock()
+	if ok {
+		h.sem.Release()
+	}
+}
+
+// release gives back the lease with the given ID, or if id is 0, the oldest
+// lease the connection holds on sem. A connection can only give back the
+// permits it holds: one that has expired was already given back, and
+// releasing it again would add a permit to sem.
+func (c *conn) release(sem *semaphore.Semaphore2, id uint64) error {
+	c.mu.Lock()
+	var h *holding
+	if id != 0 {
+		h = c.holdings[id]
+		if h == nil || h.sem != sem {
+			c.mu.Unlock()
+			return ErrLeaseExpired
+		}
+	} else {
+		for _, held := range c.holdings {
+			if held.sem == sem && (h == nil || held.id < h.id) {
+				h = held
+			}
+		}
+		if h == nil {
+			c.mu.Unlock()
+			return ErrLeaseExpired
+		}
+	}
+	delete(c.holdings, h.id)
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	c.mu.Unlock()
+	return sem.Release()
+}
+
+// disconnect abandons the acquires in flight and expires every lease
+func (c *conn) disconnect() {
+	c.nc.Close()
+	c.mu.Lock()
+	c.closed = true
+	for _, cancel := range c.pending {
+		cancel()
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+
+	c.mu.Lock()
+	holdings := c.holdings
+	c.holdings = nil
+	c.mu.Unlock()
+	for _, h := range holdings {
+		if h.timer != nil {
+			h.timer.Stop()
+		}
+		h.sem.Release()
+	}
+}
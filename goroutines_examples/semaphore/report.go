@@ -0,0 +1,170 @@
+package semaphore
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StressReport summarises a StressResult in a form that can be compared
+// across implementations, runs and machines. Fairness and ops statistics
+// cover acquirers only, as releasers never block.
+type StressReport struct {
+	Impl       string    `json:"impl"`
+	Timestamp  time.Time `json:"timestamp"`
+	Host       string    `json:"host"`
+	GOOS       string    `json:"goos"`
+	GOARCH     string    `json:"goarch"`
+	NumCPU     int       `json:"num_cpu"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+
+	Seconds    float64 `json:"seconds"`
+	Releasers  int     `json:"releasers"`
+	Acquirers  int     `json:"acquirers"`
+	ReleaseOps int     `json:"release_ops"`
+	AcquireOps int     `json:"acquire_ops"`
+
+	// Acquires per second of each acquirer
+	Throughput []float64 `json:"throughput"`
+	// Jain's fairness index of acquirer ops, from 1/n (one acquirer did
+	// everything) to 1 (all acquirers did the same amount)
+	JainFairness float64 `json:"jain_fairness"`
+
+	OpsMin int `json:"ops_min"`
+	OpsP50 int `json:"ops_p50"`
+	OpsP90 int `json:"ops_p90"`
+	OpsP99 int `json:"ops_p99"`
+	OpsMax int `json:"ops_max"`
+
+	// Time spent waiting in AcquireContext, in nanoseconds
+	WaitMean int64 `json:"wait_mean_ns"`
+	WaitP50  int64 `json:"wait_p50_ns"`
+	WaitP90  int64 `json:"wait_p90_ns"`
+	WaitP99  int64 `json:"wait_p99_ns"`
+	WaitMax  int64 `json:"wait_max_ns"`
+}
+
+// NewStressReport computes the report of a stress run of implementation impl
+func NewStressReport(impl string, r *StressResult) StressReport {
+	host, _ := os.Hostname()
+	rep := StressReport{
+		Impl:       impl,
+		Timestamp:  time.Now().UTC(),
+		Host:       host,
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Seconds:    r.Elapsed.Seconds(),
+		Releasers:  r.Config.Releasers,
+		Acquirers:  len(r.Ops) - r.Config.Releasers,
+	}
+
+	for _, n := range r.Ops[:r.Config.Releasers] {
+		rep.ReleaseOps += n
+	}
+	acquirerOps := r.Ops[r.Config.Releasers:]
+	for _, n := range acquirerOps {
+		rep.AcquireOps += n
+		rep.Throughput = append(rep.Throughput, float64(n)/rep.Seconds)
+	}
+	rep.JainFairness = JainFairness(acquirerOps)
+
+	sorted := slices.Clone(acquirerOps)
+	slices.Sort(sorted)
+	rep.OpsMin = percentile(sorted, 0)
+	rep.OpsP50 = percentile(sorted, 0.5)
+	rep.OpsP90 = percentile(sorted, 0.9)
+	rep.OpsP99 = percentile(sorted, 0.99)
+	rep.OpsMax = percentile(sorted, 1)
+
+	var waits Histogram
+	for i := range r.Waits {
+		waits.Merge(&r.Waits[i])
+	}
+	rep.WaitMean = int64(waits.Mean())
+	rep.WaitP50 = int64(waits.Quantile(0.5))
+	rep.WaitP90 = int64(waits.Quantile(0.9))
+	rep.WaitP99 = int64(waits.Quantile(0.99))
+	rep.WaitMax = int64(waits.Max)
+
+	return rep
+}
+
+// JainFairness is (Σx)² / (n·Σx²), which is 1 when every x is equal.
+// It is 1 for no samples or all zeros, as nobody was treated unfairly.
+func JainFairness(xs []int) float64 {
+	var sum, sumSq float64
+	for _, x := range xs {
+		sum += float64(x)
+		sumSq += float64(x) * float64(x)
+	}
+	if sumSq == 0 {
+		return 1
+	}
+	return sum * sum / (float64(len(xs)) * sumSq)
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted
+func percentile(sorted []int, p float64) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// WriteJSON writes each report as one line of JSON
+func WriteJSON(w io.Writer, reports ...StressReport) error {
+	enc := json.NewEncoder(w)
+	for _, rep := range reports {
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"impl", "timestamp", "host", "goos", "goarch", "num_cpu", "gomaxprocs",
+	"seconds", "releasers", "acquirers", "release_ops", "acquire_ops",
+	"jain_fairness", "ops_min", "ops_p50", "ops_p90", "ops_p99", "ops_max",
+	"wait_mean_ns", "wait_p50_ns", "wait_p90_ns", "wait_p99_ns", "wait_max_ns",
+	"throughput",
+}
+
+// WriteCSV writes a header followed by one row per report.
+// The per-acquirer throughput is the last column, separated by spaces.
+func WriteCSV(w io.Writer, reports ...StressReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, rep := range reports {
+		throughput := make([]string, len(rep.Throughput))
+		for i, t := range rep.Throughput {
+			throughput[i] = strconv.FormatFloat(t, 'f', 1, 64)
+		}
+		cw.Write([]string{
+			rep.Impl, rep.Timestamp.Format(time.RFC3339), rep.Host, rep.GOOS, rep.GOARCH,
+			strconv.Itoa(rep.NumCPU), strconv.Itoa(rep.GOMAXPROCS),
+			strconv.FormatFloat(rep.Seconds, 'f', 3, 64),
+			strconv.Itoa(rep.Releasers), strconv.Itoa(rep.Acquirers),
+			strconv.Itoa(rep.ReleaseOps), strconv.Itoa(rep.AcquireOps),
+			strconv.FormatFloat(rep.JainFairness, 'f', 4, 64),
+			strconv.Itoa(rep.OpsMin), strconv.Itoa(rep.OpsP50), strconv.Itoa(rep.OpsP90),
+			strconv.Itoa(rep.OpsP99), strconv.Itoa(rep.OpsMax),
+			strconv.FormatInt(rep.WaitMean, 10), strconv.FormatInt(rep.WaitP50, 10),
+			strconv.FormatInt(rep.WaitP90, 10), strconv.FormatInt(rep.WaitP99, 10),
+			strconv.FormatInt(rep.WaitMax, 10),
+			strings.Join(throughput, " "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
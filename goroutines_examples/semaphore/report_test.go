@@ -0,0 +1,95 @@
+package semaphore
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestJainFairness(t *testing.T) {
+	tests := []struct {
+		xs   []int
+		want float64
+	}{
+		{[]int{5, 5, 5, 5}, 1},
+		{[]int{8, 0, 0, 0}, 0.25},
+		{[]int{1, 3}, 0.8},
+		{nil, 1},
+	}
+	for _, tt := range tests {
+		if got := JainFairness(tt.xs); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("JainFairness(%v) = %v, want %v", tt.xs, got, tt.want)
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h Histogram
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	if h.Min != time.Microsecond || h.Max != 100*time.Microsecond {
+		t.Fatalf("min, max = %v, %v", h.Min, h.Max)
+	}
+	// Quantiles are bucket bounds, so at most twice the exact value
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		exact := time.Duration(q*100) * time.Microsecond
+		if got := h.Quantile(q); got < exact || got > 2*exact {
+			t.Errorf("Quantile(%v) = %v, want within [%v, %v]", q, got, exact, 2*exact)
+		}
+	}
+	if got := h.Quantile(1); got != h.Max {
+		t.Errorf("Quantile(1) = %v, want %v", got, h.Max)
+	}
+}
+
+func TestStressReport(t *testing.T) {
+	result := Stress(NewSemaphore2(0), StressConfig{
+		Releasers:  2,
+		Goroutines: 6,
+		Duration:   50 * time.Millisecond,
+	})
+	rep := NewStressReport("Semaphore2", result)
+	if rep.Releasers != 2 || rep.Acquirers != 4 || len(rep.Throughput) != 4 {
+		t.Fatalf("bad goroutine counts in %+v", rep)
+	}
+	if rep.AcquireOps == 0 || rep.AcquireOps > rep.ReleaseOps {
+		t.Fatalf("%d acquires with %d releases", rep.AcquireOps, rep.ReleaseOps)
+	}
+	if rep.JainFairness <= 0 || rep.JainFairness > 1 {
+		t.Fatalf("fairness %v outside (0, 1]", rep.JainFairness)
+	}
+	if rep.OpsMin > rep.OpsP50 || rep.OpsP50 > rep.OpsMax || rep.WaitP50 > rep.WaitMax {
+		t.Fatalf("unordered statistics in %+v", rep)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, rep, rep); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	for i := 0; i < 2; i++ {
+		var got StressReport
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.AcquireOps != rep.AcquireOps {
+			t.Fatalf("JSON round trip changed acquire ops to %d", got.AcquireOps)
+		}
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(records[1]) != len(csvHeader) {
+		t.Fatalf("CSV has %d records, want header and one row", len(records))
+	}
+}
@@ -0,0 +1,73 @@
+// Package semaphore collects the counting semaphore designs explored in
+// goroutines_examples so that they can be imported instead of copied.
+//
+// Every design satisfies SemaphoreInterface:
+//
+//   - Semaphore1 uses a buffered channel
+//   - Semaphore2 uses a daemon goroutine with an explicit FIFO queue
+//   - Semaphore3 uses a chain of linked channels
+//   - Semaphore4 uses an atomic counter, and a FIFO list under contention
+//   - PrioritySemaphore uses a daemon goroutine that serves waiters by priority
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrClosed is returned by operations on a semaphore that has been shut down
+var ErrClosed = errors.New("semaphore: closed")
+
+// ErrOverRelease is returned by Release when the semaphore already holds
+// as many permits as its capacity allows
+var ErrOverRelease = errors.New("semaphore: released beyond capacity")
+
+// Option configures a bounded semaphore
+type Option func(*options)
+
+type options struct {
+	strict bool
+}
+
+// WithStrict makes Release panic with ErrOverRelease instead of returning it
+func WithStrict() Option {
+	return func(o *options) { o.strict = true }
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) overRelease() error {
+	if o.strict {
+		panic(ErrOverRelease)
+	}
+	return ErrOverRelease
+}
+
+// checkCapacity panics unless 0 <= initial_count <= capacity
+func checkCapacity(capacity int, initial_count int) {
+	if capacity < 0 {
+		panic(fmt.Sprintf("semaphore: negative capacity %d", capacity))
+	}
+	if initial_count < 0 || initial_count > capacity {
+		panic(fmt.Sprintf("semaphore: initial count %d outside [0, %d]", initial_count, capacity))
+	}
+}
+
+// Interface for semaphores we will implement
+type SemaphoreInterface interface {
+	Acquire()
+	Release() error
+
+	// TryAcquire takes a permit without blocking, reporting whether it did
+	TryAcquire() bool
+	// AcquireContext blocks like Acquire until a permit is taken or ctx is done.
+	// When it returns an error, no permit has been taken.
+	AcquireContext(ctx context.Context) error
+}
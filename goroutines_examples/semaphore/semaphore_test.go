@@ -0,0 +1,284 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Every design must pass the same conformance suite.
+var implementations = []struct {
+	name string
+	new  func(initial_count int) SemaphoreInterface
+}{
+	{"Semaphore1", func(n int) SemaphoreInterface { return NewSemaphore1(1000, n) }},
+	{"Semaphore2", func(n int) SemaphoreInterface { return NewSemaphore2(n) }},
+	{"Semaphore3", func(n int) SemaphoreInterface { return NewSemaphore3(1000, n) }},
+	{"Semaphore4", func(n int) SemaphoreInterface { return NewSemaphore4(1000, n) }},
+	{"PrioritySemaphore", func(n int) SemaphoreInterface { return NewPrioritySemaphore(n, 0) }},
+}
+
+const blockTimeout = 100 * time.Millisecond
+
+// acquired calls Acquire in the background and reports whether it returned
+// within d. A blocked Acquire keeps running and is unblocked by a later Release.
+func acquired(s SemaphoreInterface, d time.Duration) (bool, <-chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		s.Acquire()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true, done
+	case <-time.After(d):
+		return false, done
+	}
+}
+
+func TestInitialCount(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(3)
+			for i := 0; i < 3; i++ {
+				if ok, _ := acquired(s, time.Second); !ok {
+					t.Fatalf("Acquire %d blocked with permits available", i)
+				}
+			}
+			ok, done := acquired(s, blockTimeout)
+			if ok {
+				t.Fatal("Acquire did not block with no permits available")
+			}
+			s.Release()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Release did not unblock waiter")
+			}
+		})
+	}
+}
+
+func TestReleaseBeforeAcquire(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(0)
+			s.Release()
+			s.Release()
+			for i := 0; i < 2; i++ {
+				if ok, _ := acquired(s, time.Second); !ok {
+					t.Fatalf("Acquire %d blocked after Release", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBoundedHolders(t *testing.T) {
+	const permits, workers, rounds = 3, 16, 200
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(permits)
+			var holders, maxHolders atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < rounds; j++ {
+						s.Acquire()
+						n := holders.Add(1)
+						for {
+							m := maxHolders.Load()
+							if n <= m || maxHolders.CompareAndSwap(m, n) {
+								break
+							}
+						}
+						holders.Add(-1)
+						s.Release()
+					}
+				}()
+			}
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("workers deadlocked")
+			}
+			if m := maxHolders.Load(); m > permits {
+				t.Fatalf("%d concurrent holders, want at most %d", m, permits)
+			}
+		})
+	}
+}
+
+// tryAcquireWithin retries TryAcquire until it succeeds or d elapses, as a
+// Release may take a moment to become visible to TryAcquire.
+func tryAcquireWithin(s SemaphoreInterface, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if s.TryAcquire() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func TestTryAcquire(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(1)
+			if !tryAcquireWithin(s, time.Second) {
+				t.Fatal("TryAcquire failed with a permit available")
+			}
+			if s.TryAcquire() {
+				t.Fatal("TryAcquire succeeded with no permits available")
+			}
+			s.Release()
+			if !tryAcquireWithin(s, time.Second) {
+				t.Fatal("TryAcquire failed after Release")
+			}
+		})
+	}
+}
+
+func TestAcquireContextCancelled(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(0)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if err := s.AcquireContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("AcquireContext = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			// The abandoned waiter must not swallow the next Release
+			s.Release()
+			if ok, _ := acquired(s, time.Second); !ok {
+				t.Fatal("Release was consumed by a cancelled waiter")
+			}
+		})
+	}
+}
+
+func TestAcquireContextLeavesQueue(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(0)
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error)
+			go func() { errCh <- s.AcquireContext(ctx) }()
+			time.Sleep(20 * time.Millisecond)
+
+			// Queue a second waiter behind the first, then cancel the first
+			ok, done := acquired(s, 20*time.Millisecond)
+			if ok {
+				t.Fatal("Acquire did not block with no permits available")
+			}
+			cancel()
+			if err := <-errCh; !errors.Is(err, context.Canceled) {
+				t.Fatalf("AcquireContext = %v, want %v", err, context.Canceled)
+			}
+
+			s.Release()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("waiter behind a cancelled waiter was not unblocked")
+			}
+		})
+	}
+}
+
+func TestAcquireContextRacingRelease(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(0)
+			for i := 0; i < 200; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				errCh := make(chan error)
+				go func() { errCh <- s.AcquireContext(ctx) }()
+				go s.Release()
+				go cancel()
+
+				// The single permit is either held by the waiter or still available
+				if err := <-errCh; err != nil {
+					if ok, _ := acquired(s, time.Second); !ok {
+						t.Fatalf("round %d: permit lost after %v", i, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Designs that enforce a capacity
+var bounded = []struct {
+	name string
+	new  func(capacity int, initial_count int, opts ...Option) SemaphoreInterface
+}{
+	{"Semaphore1", func(c, n int, opts ...Option) SemaphoreInterface { return NewSemaphore1(c, n, opts...) }},
+	{"Semaphore3", func(c, n int, opts ...Option) SemaphoreInterface { return NewSemaphore3(c, n, opts...) }},
+	{"Semaphore4", func(c, n int, opts ...Option) SemaphoreInterface { return NewSemaphore4(c, n, opts...) }},
+}
+
+func TestOverRelease(t *testing.T) {
+	for _, impl := range bounded {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(2, 1)
+			if err := s.Release(); err != nil {
+				t.Fatalf("Release below capacity = %v", err)
+			}
+			if err := s.Release(); err != ErrOverRelease {
+				t.Fatalf("Release at capacity = %v, want %v", err, ErrOverRelease)
+			}
+			for i := 0; i < 2; i++ {
+				if ok, _ := acquired(s, time.Second); !ok {
+					t.Fatalf("Acquire %d blocked at capacity", i)
+				}
+			}
+			if ok, _ := acquired(s, blockTimeout); ok {
+				t.Fatal("over-release added a permit")
+			}
+		})
+	}
+}
+
+func TestStrictOverRelease(t *testing.T) {
+	for _, impl := range bounded {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(1, 1, WithStrict())
+			defer func() {
+				if r := recover(); r != ErrOverRelease {
+					t.Fatalf("Release panicked with %v, want %v", r, ErrOverRelease)
+				}
+			}()
+			s.Release()
+		})
+	}
+}
+
+func TestInvalidCapacity(t *testing.T) {
+	for _, impl := range bounded {
+		for _, args := range [][2]int{{-1, 0}, {2, 3}, {2, -1}} {
+			t.Run(fmt.Sprintf("%s/%d,%d", impl.name, args[0], args[1]), func(t *testing.T) {
+				defer func() {
+					if recover() == nil {
+						t.Fatal("constructor accepted invalid arguments")
+					}
+				}()
+				impl.new(args[0], args[1])
+			})
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zzkzzzz/Go_practices/goroutines_examples/semaphore"
+)
+
+type semaphoreImpl struct {
+	flag string // Value of -impl that selects it
+	name string
+	new  func() semaphore.SemaphoreInterface
+}
+
+// Every implementation starts with no permits available
+var semaphoreImpls = []semaphoreImpl{
+	{"1", "Semaphore1", func() semaphore.SemaphoreInterface { return semaphore.NewSemaphore1(1000000, 0) }},
+	{"2", "Semaphore2", func() semaphore.SemaphoreInterface { return semaphore.NewSemaphore2(0) }},
+	{"3", "Semaphore3", func() semaphore.SemaphoreInterface { return semaphore.NewSemaphore3(1000000, 0) }},
+	{"4", "Semaphore4", func() semaphore.SemaphoreInterface { return semaphore.NewSemaphore4(1000000, 0) }},
+	{"priority", "PrioritySemaphore", func() semaphore.SemaphoreInterface { return semaphore.NewPrioritySemaphore(0, 0) }},
+}
+
+func implFlags() string {
+	names := make([]string, len(semaphoreImpls))
+	for i, impl := range semaphoreImpls {
+		names[i] = impl.flag
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectImpls parses a comma-separated list of implementations, or "all"
+func selectImpls(list string) ([]semaphoreImpl, error) {
+	if list == "all" {
+		return semaphoreImpls, nil
+	}
+	var impls []semaphoreImpl
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if seen[f] {
+			return nil, usagef("-impl %s given twice", f)
+		}
+		seen[f] = true
+		found := false
+		for _, impl := range semaphoreImpls {
+			if impl.flag == f {
+				impls = append(impls, impl)
+				found = true
+			}
+		}
+		if !found {
+			return nil, usagef("unknown -impl %q, want all or a list of %s", f, implFlags())
+		}
+	}
+	return impls, nil
+}
+
+func closeSemaphore(s semaphore.SemaphoreInterface) {
+	if c, ok := s.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func runSemaphoreStress(fs *flag.FlagSet, args []string) error {
+	implList := fs.String("impl", "all", "implementations to run: all, or a comma-separated list of "+implFlags())
+	releasers := fs.Int("releasers", 1, "number of goroutines calling Release")
+	goroutines := fs.Int("goroutines", 4, "total number of goroutines, the rest calling Acquire")
+	duration := fs.Duration("duration", time.Second, "how long acquirers run")
+	format := fs.String("format", "text", "report format: text, json or csv")
+	verbose := fs.Bool("v", false, "log every operation to stdout")
+	metrics := fs.String("metrics", "", "address to serve Prometheus /metrics and expvar /debug/vars on while running")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	impls, err := selectImpls(*implList)
+	if err != nil {
+		return err
+	}
+	if *releasers < 0 {
+		return usagef("-releasers must not be negative, got %d", *releasers)
+	}
+	if *goroutines <= *releasers {
+		return usagef("-goroutines must exceed -releasers (%d), got %d", *releasers, *goroutines)
+	}
+	if err := positiveDuration("duration", *duration); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		return usagef("unknown -format %q, want text, json or csv", *format)
+	}
+
+	cfg := semaphore.StressConfig{
+		Releasers:  *releasers,
+		Goroutines: *goroutines,
+		Duration:   *duration,
+	}
+	if *verbose {
+		cfg.Log = os.Stdout
+	}
+
+	// The implementation being stressed, if metrics are served
+	var current atomic.Pointer[semaphore.Instrumented]
+	if *metrics != "" {
+		l, err := net.Listen("tcp", *metrics)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			if s := current.Load(); s != nil {
+				semaphore.PrometheusHandler(s).ServeHTTP(w, r)
+			}
+		})
+		go http.Serve(l, mux)
+		fmt.Fprintf(os.Stderr, "serving metrics on http://%s/metrics\n", l.Addr())
+	}
+
+	var reports []semaphore.StressReport
+	for _, impl := range impls {
+		sem := impl.new()
+		s := sem
+		if *metrics != "" {
+			in := semaphore.NewInstrumented(impl.name, sem)
+			if err := in.PublishExpvar(); err != nil {
+				closeSemaphore(sem)
+				return err
+			}
+			current.Store(in)
+			s = in
+		}
+		result := semaphore.Stress(s, cfg)
+		closeSemaphore(sem)
+
+		if *format == "text" {
+			fmt.Print(impl.name)
+			for _, ops := range result.Ops {
+				fmt.Printf("\t%d", ops)
+			}
+			fmt.Println()
+		}
+		reports = append(reports, semaphore.NewStressReport(impl.name, result))
+	}
+
+	switch *format {
+	case "json":
+		return semaphore.WriteJSON(os.Stdout, reports...)
+	case "csv":
+		return semaphore.WriteCSV(os.Stdout, reports...)
+	}
+	return nil
+}
+
+func runSemaphoreFIFO(fs *flag.FlagSet, args []string) error {
+	implList := fs.String("impl", "all", "implementations to verify: all, or a comma-separated list of "+implFlags())
+	waiters := fs.Int("waiters", 10, "number of waiters")
+	settle := fs.Duration("settle", 50*time.Millisecond, "time given to each waiter to block before the next starts")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	impls, err := selectImpls(*implList)
+	if err != nil {
+		return err
+	}
+	if err := positive("waiters", *waiters); err != nil {
+		return err
+	}
+	if err := positiveDuration("settle", *settle); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, impl := range impls {
+		s := impl.new()
+		result, err := semaphore.VerifyFIFO(s, *waiters, *settle)
+		closeSemaphore(s)
+		if err != nil {
+			fmt.Printf("%s: %v\n", impl.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: FIFO order verified for %d waiters\n", impl.name, len(result.Granted))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d implementations failed", failed, len(impls))
+	}
+	return nil
+}